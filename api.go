@@ -18,6 +18,11 @@ func SetBackend(backend_ Backend) {
 	backend = backend_
 }
 
+// Gets the current backend. Can be nil.
+func GetBackend() Backend {
+	return backend
+}
+
 // Configures the current backend. Verbosity is mapped to maximum
 // loggable level as follows:
 //
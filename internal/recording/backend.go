@@ -0,0 +1,133 @@
+// Test helpers shared by the tests of commonlog's packages.
+package recording
+
+import (
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tliron/commonlog"
+)
+
+// Sets a [Backend] as the current backend for the duration of the test.
+func Use(t *testing.T) *Backend {
+	previous := commonlog.GetBackend()
+	backend := NewBackend()
+	commonlog.SetBackend(backend)
+	t.Cleanup(func() {
+		commonlog.SetBackend(previous)
+	})
+	return backend
+}
+
+//
+// Message
+//
+
+type Message struct {
+	Level   commonlog.Level
+	Name    []string
+	Message *commonlog.LinearMessage
+}
+
+// Returns nil if the key was not set.
+func (self *Message) Get(key string) any {
+	for _, value := range self.Message.Values {
+		if value.Key == key {
+			return value.Value
+		}
+	}
+	return nil
+}
+
+// Returns the values as a map.
+func (self *Message) GetValues() map[string]any {
+	values := make(map[string]any)
+	for _, value := range self.Message.Values {
+		values[value.Key] = value.Value
+	}
+	return values
+}
+
+//
+// Backend
+//
+
+// [commonlog.Backend] that records the messages it sends.
+type Backend struct {
+	messages      []Message
+	lock          sync.Mutex
+	nameHierarchy *commonlog.NameHierarchy
+}
+
+func NewBackend() *Backend {
+	self := Backend{
+		nameHierarchy: commonlog.NewNameHierarchy(),
+	}
+	self.nameHierarchy.SetMaxLevel(commonlog.Debug)
+	return &self
+}
+
+func (self *Backend) GetMessages() []Message {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	return append(self.messages[:0:0], self.messages...)
+}
+
+// For sinks that send messages asynchronously.
+func (self *Backend) WaitForMessages(t *testing.T, count int) []Message {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if messages := self.GetMessages(); (len(messages) >= count) || time.Now().After(deadline) {
+			if len(messages) != count {
+				t.Fatalf("got %d messages, expected %d", len(messages), count)
+			}
+			return messages
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// ([commonlog.Backend] interface)
+func (self *Backend) Configure(verbosity int, path *string) {
+}
+
+// ([commonlog.Backend] interface)
+func (self *Backend) GetWriter() io.Writer {
+	return nil
+}
+
+// ([commonlog.Backend] interface)
+func (self *Backend) NewMessage(level commonlog.Level, depth int, name ...string) commonlog.Message {
+	if self.AllowLevel(level, name...) {
+		return commonlog.NewLinearMessage(func(message *commonlog.LinearMessage) {
+			self.lock.Lock()
+			defer self.lock.Unlock()
+
+			self.messages = append(self.messages, Message{
+				Level:   level,
+				Name:    name,
+				Message: message,
+			})
+		})
+	} else {
+		return nil
+	}
+}
+
+// ([commonlog.Backend] interface)
+func (self *Backend) AllowLevel(level commonlog.Level, name ...string) bool {
+	return self.nameHierarchy.AllowLevel(level, name...)
+}
+
+// ([commonlog.Backend] interface)
+func (self *Backend) SetMaxLevel(level commonlog.Level, name ...string) {
+	self.nameHierarchy.SetMaxLevel(level, name...)
+}
+
+// ([commonlog.Backend] interface)
+func (self *Backend) GetMaxLevel(name ...string) commonlog.Level {
+	return self.nameHierarchy.GetMaxLevel(name...)
+}
@@ -4,11 +4,15 @@ import (
 	"fmt"
 	"io"
 	"strings"
+	"time"
 
 	"github.com/coreos/go-systemd/journal"
 	"github.com/tliron/commonlog"
+	"github.com/tliron/commonlog/simple"
 )
 
+const DefaultNameField = "LOGGER"
+
 func init() {
 	backend := NewBackend()
 	backend.Configure(0, nil)
@@ -19,15 +23,37 @@ func init() {
 // Backend
 //
 
+// Sends messages to journald.
+//
+// The name is sent as a NameField journal field (set NameField to an empty
+// string to omit it). If SyslogIdentifier is not empty it is sent as the
+// "SYSLOG_IDENTIFIER" field, otherwise journald will use the process name.
+// Keys that would be converted to these or other fields that we set
+// ourselves are prefixed with "X_" (see [ToFieldName]).
+//
+// The path argument of Configure, if provided, overrides SocketPath. If
+// journald is not available at the socket path then Configure will
+// configure the Fallback backend (by default a [simple.Backend] writing to
+// stderr) and all messages will be sent to it instead. Set Fallback to nil
+// to disable this behavior.
 type Backend struct {
-	VarsInMessage bool
-
+	VarsInMessage    bool
+	SocketPath       string
+	NameField        string
+	SyslogIdentifier string
+	Fallback         commonlog.Backend
+
+	sender        *Sender
+	fallback      bool
 	nameHierarchy *commonlog.NameHierarchy
 	writer        io.Writer
 }
 
 func NewBackend() *Backend {
 	return &Backend{
+		SocketPath:    DefaultSocketPath,
+		NameField:     DefaultNameField,
+		Fallback:      simple.NewBackend(),
 		nameHierarchy: commonlog.NewNameHierarchy(),
 	}
 }
@@ -36,22 +62,52 @@ func NewBackend() *Backend {
 func (self *Backend) Configure(verbosity int, path *string) {
 	maxLevel := commonlog.VerbosityToMaxLevel(verbosity)
 
+	socketPath := self.SocketPath
+	if path != nil {
+		socketPath = *path
+	}
+
+	// Note: we are keeping the sender (and its socket) if the path has not changed
+	if (self.sender == nil) || (self.sender.SocketPath != socketPath) {
+		self.sender = NewSender(socketPath)
+	}
+
+	self.fallback = false
+
 	if maxLevel == commonlog.None {
 		self.writer = io.Discard
 		self.nameHierarchy.SetMaxLevel(commonlog.None)
 	} else {
-		self.writer = JournalWriter{}
+		if (self.Fallback != nil) && !self.sender.Enabled() {
+			self.Fallback.Configure(verbosity, nil)
+			self.fallback = true
+		}
+
+		self.writer = JournalWriter{Sender: self.sender}
 		self.nameHierarchy.SetMaxLevel(maxLevel)
 	}
 }
 
+// Whether messages are sent to the Fallback backend because journald was
+// not available when Configure was called.
+func (self *Backend) IsFallback() bool {
+	return self.fallback
+}
+
 // ([commonlog.Backend] interface)
 func (self *Backend) GetWriter() io.Writer {
+	if self.fallback {
+		return self.Fallback.GetWriter()
+	}
 	return self.writer
 }
 
 // ([commonlog.Backend] interface)
 func (self *Backend) NewMessage(level commonlog.Level, depth int, name ...string) commonlog.Message {
+	if self.fallback {
+		return self.Fallback.NewMessage(level, depth+1, name...)
+	}
+
 	if self.AllowLevel(level, name...) {
 		var priority journal.Priority
 		switch level {
@@ -71,29 +127,45 @@ func (self *Backend) NewMessage(level commonlog.Level, depth int, name ...string
 			panic(fmt.Sprintf("unsupported log level: %d", level))
 		}
 
-		var prefix string
-		if name := strings.Join(name, "."); len(name) > 0 {
-			prefix = "[" + name + "] "
+		message := NewMessage(self.sender, priority, self.VarsInMessage, time.Now()).(*Message)
+		message.nameField = self.NameField
+
+		if self.NameField != "" {
+			if name := strings.Join(name, "."); name != "" {
+				message.setField(self.NameField, name)
+			}
 		}
 
-		return commonlog.TraceMessage(NewMessage(priority, prefix, self.VarsInMessage), depth)
+		if self.SyslogIdentifier != "" {
+			message.setField("SYSLOG_IDENTIFIER", self.SyslogIdentifier)
+		}
+
+		return commonlog.TraceMessage(message, depth)
 	} else {
 		return nil
 	}
-
 }
 
 // ([commonlog.Backend] interface)
 func (self *Backend) AllowLevel(level commonlog.Level, name ...string) bool {
+	if self.fallback {
+		return self.Fallback.AllowLevel(level, name...)
+	}
 	return self.nameHierarchy.AllowLevel(level, name...)
 }
 
 // ([commonlog.Backend] interface)
 func (self *Backend) SetMaxLevel(level commonlog.Level, name ...string) {
 	self.nameHierarchy.SetMaxLevel(level, name...)
+	if self.fallback {
+		self.Fallback.SetMaxLevel(level, name...)
+	}
 }
 
 // ([commonlog.Backend] interface)
 func (self *Backend) GetMaxLevel(name ...string) commonlog.Level {
+	if self.fallback {
+		return self.Fallback.GetMaxLevel(name...)
+	}
 	return self.nameHierarchy.GetMaxLevel(name...)
 }
@@ -0,0 +1,103 @@
+package journal
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/tliron/commonlog"
+	"github.com/tliron/commonlog/simple"
+)
+
+func TestBackend(t *testing.T) {
+	conn, path := listenJournal(t)
+
+	backend := NewBackend()
+	backend.SyslogIdentifier = "app"
+	backend.Configure(0, &path)
+
+	if backend.IsFallback() {
+		t.Fatal("IsFallback() = true")
+	}
+
+	if message := backend.NewMessage(commonlog.Info, 0, "a", "b"); message != nil {
+		t.Error("expected Info to be filtered out at verbosity 0")
+	}
+
+	backend.NewMessage(commonlog.Error, 0, "a", "b").
+		Set(commonlog.MESSAGE, "hello").
+		Set("group.key", 1).
+		Set("_private", "x").
+		Set("priority", "7").
+		Set("syslog_identifier", "forged").
+		Set("logger", "forged").
+		Set("code_file", "forged").
+		Send()
+
+	fields := readJournalEntry(t, conn)
+	delete(fields, "SYSLOG_TIMESTAMP")
+	expected := map[string]string{
+		"PRIORITY":              "3",
+		"MESSAGE":               "hello",
+		DefaultNameField:        "a.b",
+		"SYSLOG_IDENTIFIER":     "app",
+		"GROUP_KEY":             "1",
+		"X_PRIVATE":             "x",
+		"X_PRIORITY":            "7",
+		"X_SYSLOG_IDENTIFIER":   "forged",
+		"X_" + DefaultNameField: "forged",
+		"X_CODE_FILE":           "forged",
+	}
+	if !equalFields(fields, expected) {
+		t.Errorf("fields = %q, expected %q", fields, expected)
+	}
+}
+
+func TestBackendFallback(t *testing.T) {
+	fallback := simple.NewBackend()
+
+	backend := NewBackend()
+	backend.SocketPath = filepath.Join(t.TempDir(), "missing")
+	backend.Fallback = fallback
+	backend.Configure(1, nil)
+
+	if !backend.IsFallback() {
+		t.Fatal("IsFallback() = false")
+	}
+
+	if level := fallback.GetMaxLevel(); level != commonlog.Info {
+		t.Errorf("fallback max level = %s, expected %s", level, commonlog.Info)
+	}
+
+	backend.SetMaxLevel(commonlog.Debug, "a")
+	if !backend.AllowLevel(commonlog.Debug, "a") || !fallback.AllowLevel(commonlog.Debug, "a") {
+		t.Error("max level was not set on the fallback")
+	}
+
+	if backend.GetWriter() != fallback.GetWriter() {
+		t.Error("GetWriter() did not return the fallback's writer")
+	}
+}
+
+func TestToFieldName(t *testing.T) {
+	tests := map[string]string{
+		"key":        "KEY",
+		"group.key":  "GROUP_KEY",
+		"with-dash":  "WITH_DASH",
+		"_private":   "X_PRIVATE",
+		"":           "X",
+		"ünicode":    "X_NICODE",
+		"message":    "X_MESSAGE",
+		"priority":   "X_PRIORITY",
+		"syslog.pid": "X_SYSLOG_PID",
+		"code_line":  "X_CODE_LINE",
+		"logger":     "LOGGER",
+	}
+
+	for key, expected := range tests {
+		if name := ToFieldName(key); name != expected {
+			t.Errorf("ToFieldName(%q) = %q, expected %q", key, name, expected)
+		} else if !IsValidFieldName(name) {
+			t.Errorf("ToFieldName(%q) = %q is not valid", key, name)
+		}
+	}
+}
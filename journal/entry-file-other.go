@@ -0,0 +1,28 @@
+//go:build !linux
+
+package journal
+
+import (
+	"os"
+)
+
+// Creates an unlinked temporary file containing the data (memfd is not
+// available on this platform).
+func newEntryFile(data []byte) (*os.File, error) {
+	file, err := os.CreateTemp("", "journal-entry.")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.Remove(file.Name()); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return file, nil
+}
@@ -0,0 +1,31 @@
+//go:build linux
+
+package journal
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// Creates a sealed memfd containing the data, which is what journald
+// prefers.
+func newEntryFile(data []byte) (*os.File, error) {
+	fd, err := unix.MemfdCreate("journal-entry", unix.MFD_CLOEXEC|unix.MFD_ALLOW_SEALING)
+	if err != nil {
+		return nil, err
+	}
+	file := os.NewFile(uintptr(fd), "journal-entry")
+
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	if _, err := unix.FcntlInt(file.Fd(), unix.F_ADD_SEALS, unix.F_SEAL_SHRINK|unix.F_SEAL_GROW|unix.F_SEAL_WRITE|unix.F_SEAL_SEAL); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return file, nil
+}
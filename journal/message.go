@@ -2,6 +2,7 @@ package journal
 
 import (
 	"strings"
+	"time"
 
 	"github.com/coreos/go-systemd/journal"
 	"github.com/tliron/commonlog"
@@ -13,24 +14,34 @@ import (
 //
 
 type Message struct {
+	sender        *Sender
 	priority      journal.Priority
-	prefix        string
 	postfix       string
 	message       string
 	vars          map[string]string
 	varsInMessage bool
+	time          time.Time
+	nameField     string
 }
 
-func NewMessage(priority journal.Priority, prefix string, varsInMessage bool) commonlog.Message {
+func NewMessage(sender *Sender, priority journal.Priority, varsInMessage bool, time time.Time) commonlog.Message {
 	return &Message{
+		sender:        sender,
 		priority:      priority,
-		prefix:        prefix,
 		varsInMessage: varsInMessage,
+		time:          time,
 	}
 }
 
 // ([commonlog.Message] interface)
 func (self *Message) Set(key string, value any) commonlog.Message {
+	if key == commonlog.TIME {
+		if time_, ok := commonlog.ToTime(value); ok {
+			self.time = time_
+		}
+		return self
+	}
+
 	value_ := util.ToString(value)
 
 	switch key {
@@ -54,17 +65,13 @@ func (self *Message) Set(key string, value any) commonlog.Message {
 			key = "CODE_LINE"
 
 		default:
-			key = strings.ToUpper(key)
-			if strings.HasPrefix(key, "_") {
-				// Field name cannot be set by user
-				key = "X" + key
+			if key = ToFieldName(key); key == self.nameField {
+				// Field name is reserved for the name
+				key = "X_" + key
 			}
 		}
 
-		if self.vars == nil {
-			self.vars = make(map[string]string)
-		}
-		self.vars[key] = value_
+		self.setField(key, value_)
 	}
 
 	return self
@@ -72,12 +79,61 @@ func (self *Message) Set(key string, value any) commonlog.Message {
 
 // ([commonlog.Message] interface)
 func (self *Message) Send() {
-	message := self.prefix + self.message
+	message := self.message
 	if self.postfix != "" {
 		if message != "" {
 			message += " "
 		}
 		message += "{" + self.postfix + "}"
 	}
-	journal.Send(message, self.priority, self.vars)
+
+	if !self.time.IsZero() {
+		self.setField("SYSLOG_TIMESTAMP", self.time.Format(time.RFC3339Nano))
+	}
+
+	self.sender.Send(message, self.priority, self.vars)
+}
+
+func (self *Message) setField(name string, value string) {
+	if self.vars == nil {
+		self.vars = make(map[string]string)
+	}
+	self.vars[name] = value
+}
+
+// Converts a key to a valid journal field name (see [IsValidFieldName]) by
+// converting it to uppercase and replacing invalid characters with
+// underscores. Because a leading underscore is reserved for trusted fields
+// we will prefix such names with "X". Likewise, the fields that we set
+// ourselves ("MESSAGE", "PRIORITY", "SYSLOG_*", and "CODE_*") are prefixed
+// with "X_".
+func ToFieldName(key string) string {
+	key = strings.Map(func(r rune) rune {
+		switch {
+		case ('A' <= r) && (r <= 'Z'), ('0' <= r) && (r <= '9'), r == '_':
+			return r
+		case ('a' <= r) && (r <= 'z'):
+			return r - 'a' + 'A'
+		default:
+			return '_'
+		}
+	}, key)
+
+	if (key == "") || strings.HasPrefix(key, "_") {
+		// Field name cannot be set by user
+		key = "X" + key
+	} else if isReservedFieldName(key) {
+		key = "X_" + key
+	}
+
+	return key
+}
+
+func isReservedFieldName(name string) bool {
+	switch name {
+	case "MESSAGE", "PRIORITY":
+		return true
+	default:
+		return strings.HasPrefix(name, "SYSLOG_") || strings.HasPrefix(name, "CODE_")
+	}
 }
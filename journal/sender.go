@@ -0,0 +1,150 @@
+package journal
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/coreos/go-systemd/journal"
+)
+
+const DefaultSocketPath = "/run/systemd/journal/socket"
+
+//
+// Sender
+//
+
+// Sends entries to journald over its native protocol. Unlike
+// [journal.Send] the socket path is configurable, e.g. for testing against
+// a local unixgram socket.
+//
+// See: https://systemd.io/JOURNAL_NATIVE_PROTOCOL/
+type Sender struct {
+	SocketPath string
+
+	conn     *net.UnixConn
+	connLock sync.Mutex
+}
+
+func NewSender(socketPath string) *Sender {
+	return &Sender{
+		SocketPath: socketPath,
+	}
+}
+
+// Checks whether journald is listening on the socket path.
+func (self *Sender) Enabled() bool {
+	if _, err := self.getConn(); err != nil {
+		return false
+	}
+
+	if conn, err := net.Dial("unixgram", self.SocketPath); err == nil {
+		conn.Close()
+		return true
+	} else {
+		return false
+	}
+}
+
+// Fields with invalid names are ignored. See [IsValidFieldName].
+func (self *Sender) Send(message string, priority journal.Priority, vars map[string]string) error {
+	conn, err := self.getConn()
+	if err != nil {
+		return err
+	}
+
+	address := net.UnixAddr{
+		Name: self.SocketPath,
+		Net:  "unixgram",
+	}
+
+	var data bytes.Buffer
+	appendField(&data, "PRIORITY", strconv.Itoa(int(priority)))
+	appendField(&data, "MESSAGE", message)
+	for name, value := range vars {
+		if IsValidFieldName(name) {
+			appendField(&data, name, value)
+		}
+	}
+
+	if _, _, err := conn.WriteMsgUnix(data.Bytes(), nil, &address); err == nil {
+		return nil
+	} else if !isSocketSpaceError(err) {
+		return err
+	}
+
+	// Large entries are sent via a file descriptor
+	file, err := newEntryFile(data.Bytes())
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, _, err = conn.WriteMsgUnix(nil, syscall.UnixRights(int(file.Fd())), &address)
+	return err
+}
+
+func (self *Sender) getConn() (*net.UnixConn, error) {
+	self.connLock.Lock()
+	defer self.connLock.Unlock()
+
+	if self.conn == nil {
+		// Unbound socket (we only send)
+		if address, err := net.ResolveUnixAddr("unixgram", ""); err == nil {
+			if self.conn, err = net.ListenUnixgram("unixgram", address); err != nil {
+				return nil, err
+			}
+		} else {
+			return nil, err
+		}
+	}
+
+	return self.conn, nil
+}
+
+// Field names must consist of uppercase letters, digits, and underscores,
+// and must not begin with an underscore.
+func IsValidFieldName(name string) bool {
+	if (name == "") || (name[0] == '_') {
+		return false
+	}
+
+	for _, r := range name {
+		if !((('A' <= r) && (r <= 'Z')) || (('0' <= r) && (r <= '9')) || (r == '_')) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Utils
+
+func appendField(writer *bytes.Buffer, name string, value string) {
+	if strings.ContainsRune(value, '\n') {
+		// Binary-safe format: name, newline, little-endian 64-bit size, value, newline
+		writer.WriteString(name)
+		writer.WriteByte('\n')
+		binary.Write(writer, binary.LittleEndian, uint64(len(value)))
+		writer.WriteString(value)
+		writer.WriteByte('\n')
+	} else {
+		writer.WriteString(name)
+		writer.WriteByte('=')
+		writer.WriteString(value)
+		writer.WriteByte('\n')
+	}
+}
+
+func isSocketSpaceError(err error) bool {
+	var errno syscall.Errno
+	if errors.As(err, &errno) {
+		return (errno == syscall.EMSGSIZE) || (errno == syscall.ENOBUFS)
+	}
+	return false
+}
@@ -0,0 +1,164 @@
+package journal
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/coreos/go-systemd/journal"
+)
+
+func TestSender(t *testing.T) {
+	conn, path := listenJournal(t)
+
+	sender := NewSender(path)
+	if !sender.Enabled() {
+		t.Fatal("Enabled() = false")
+	}
+
+	if err := sender.Send("hello\nworld", journal.PriWarning, map[string]string{
+		"KEY":      "value",
+		"invalid":  "dropped",
+		"_TRUSTED": "dropped",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	fields := readJournalEntry(t, conn)
+	expected := map[string]string{
+		"PRIORITY": "4",
+		"MESSAGE":  "hello\nworld",
+		"KEY":      "value",
+	}
+	if !equalFields(fields, expected) {
+		t.Errorf("fields = %q, expected %q", fields, expected)
+	}
+}
+
+func TestSenderLargeEntry(t *testing.T) {
+	conn, path := listenJournal(t)
+
+	// Larger than the maximum datagram size
+	message := strings.Repeat("x", 1<<20)
+	if err := NewSender(path).Send(message, journal.PriInfo, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if fields := readJournalEntry(t, conn); fields["MESSAGE"] != message {
+		t.Errorf("MESSAGE has length %d, expected %d", len(fields["MESSAGE"]), len(message))
+	}
+}
+
+func TestSenderNotEnabled(t *testing.T) {
+	if NewSender(filepath.Join(t.TempDir(), "missing")).Enabled() {
+		t.Error("Enabled() = true for a missing socket")
+	}
+}
+
+func TestIsValidFieldName(t *testing.T) {
+	tests := map[string]bool{
+		"MESSAGE":   true,
+		"CODE_LINE": true,
+		"X1":        true,
+		"":          false,
+		"_PID":      false,
+		"lowercase": false,
+		"WITH.DOT":  false,
+		"WITH-DASH": false,
+	}
+
+	for name, expected := range tests {
+		if valid := IsValidFieldName(name); valid != expected {
+			t.Errorf("IsValidFieldName(%q) = %t, expected %t", name, valid, expected)
+		}
+	}
+}
+
+// Utils
+
+func listenJournal(t *testing.T) (*net.UnixConn, string) {
+	path := filepath.Join(t.TempDir(), "socket")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		conn.Close()
+	})
+	return conn, path
+}
+
+// Reads an entry sent either as a datagram or via a file descriptor.
+func readJournalEntry(t *testing.T, conn *net.UnixConn) map[string]string {
+	buffer := make([]byte, 1<<16)
+	oob := make([]byte, syscall.CmsgSpace(4))
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	count, oobCount, _, _, err := conn.ReadMsgUnix(buffer, oob)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := buffer[:count]
+	if oobCount > 0 {
+		messages, err := syscall.ParseSocketControlMessage(oob[:oobCount])
+		if err != nil {
+			t.Fatal(err)
+		}
+		fds, err := syscall.ParseUnixRights(&messages[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		file := os.NewFile(uintptr(fds[0]), "entry")
+		defer file.Close()
+		if data, err = io.ReadAll(io.NewSectionReader(file, 0, 1<<30)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return parseJournalEntry(t, data)
+}
+
+// See: https://systemd.io/JOURNAL_NATIVE_PROTOCOL/
+func parseJournalEntry(t *testing.T, data []byte) map[string]string {
+	fields := make(map[string]string)
+	for len(data) > 0 {
+		line, rest, _ := bytes.Cut(data, []byte("\n"))
+		if name, value, ok := bytes.Cut(line, []byte("=")); ok {
+			fields[string(name)] = string(value)
+			data = rest
+		} else {
+			// Binary-safe format
+			if len(rest) < 8 {
+				t.Fatalf("malformed entry: %q", data)
+			}
+			length := binary.LittleEndian.Uint64(rest[:8])
+			rest = rest[8:]
+			if uint64(len(rest)) < length+1 {
+				t.Fatalf("malformed entry: %q", data)
+			}
+			fields[string(line)] = string(rest[:length])
+			data = rest[length+1:]
+		}
+	}
+	return fields
+}
+
+func equalFields(fields map[string]string, expected map[string]string) bool {
+	if len(fields) != len(expected) {
+		return false
+	}
+	for name, value := range expected {
+		if fields[name] != value {
+			return false
+		}
+	}
+	return true
+}
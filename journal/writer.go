@@ -9,10 +9,17 @@ import (
 // JournalWriter
 //
 
-type JournalWriter struct{}
+// If Sender is nil will use [journal.Send].
+type JournalWriter struct {
+	Sender *Sender
+}
 
 // ([io.Writer] interface)
 func (self JournalWriter) Write(p []byte) (int, error) {
-	journal.Send(util.BytesToString(p), journal.PriDebug, nil)
+	if self.Sender != nil {
+		self.Sender.Send(util.BytesToString(p), journal.PriDebug, nil)
+	} else {
+		journal.Send(util.BytesToString(p), journal.PriDebug, nil)
+	}
 	return len(p), nil
 }
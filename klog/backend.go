@@ -1,9 +1,13 @@
 package klog
 
 import (
+	"flag"
 	"fmt"
 	"io"
 	"os"
+	"strconv"
+	"strings"
+	"sync"
 
 	"github.com/tliron/commonlog"
 	"github.com/tliron/go-kutil/util"
@@ -13,6 +17,11 @@ import (
 const (
 	LogFileWritePermissions = 0600
 	DefaultBufferSize       = 1_000
+	DefaultInfoVerbosity    = 2
+	DefaultDebugVerbosity   = 4
+	NameKey                 = "logger"
+	ScopeKey                = "scope"
+	ErrorKey                = "err"
 )
 
 func init() {
@@ -25,19 +34,53 @@ func init() {
 // Backend
 //
 
+// Sends messages to klog using its structured API (InfoS and ErrorS) with
+// native key-value pairs. The name is set as a "logger" key and the scope
+// as a "scope" key. An "err" key with an error value on error messages
+// becomes the error argument.
+//
+// Levels are mapped as follows:
+//
+//   - [commonlog.Critical] and [commonlog.Error]: ErrorS (Critical does
+//     not exit)
+//   - [commonlog.Warning]: Warning (klog has no structured warning, so
+//     the key-value pairs are formatted into the text like InfoS does)
+//   - [commonlog.Notice]: InfoS
+//   - [commonlog.Info]: V(InfoVerbosity).InfoS
+//   - [commonlog.Debug]: V(DebugVerbosity).InfoS
+//
+// klog's "-v" flag is kept in sync with the max level: Configure and
+// SetMaxLevel raise it according to the highest max level of the root and
+// all names (so that klog will not filter out our messages). Note that
+// this is a global side effect: while a max level of [commonlog.Info] or
+// [commonlog.Debug] is set for any name, other klog users in the process
+// (e.g. client-go) will also emit their V(InfoVerbosity) or
+// V(DebugVerbosity) output. The flag is lowered again when no name needs
+// it, but never below the value set by the user, e.g. by parsing klog's
+// flags. In the other direction, call [Backend.SyncFromKlogFlags] after
+// parsing klog's flags (see [klog.InitFlags]). When klog's "-vmodule" flag
+// is set it can enable Info and Debug messages for specific source files.
 type Backend struct {
-	BufferSize int
-	Buffered   bool
+	BufferSize     int
+	Buffered       bool
+	InfoVerbosity  klog.Level
+	DebugVerbosity klog.Level
 
 	writer        io.Writer
 	nameHierarchy *commonlog.NameHierarchy
+
+	userVerbosity klog.Level // as set by the user
+	ownVerbosity  klog.Level // as last set by us
+	verbosityLock sync.Mutex
 }
 
 func NewBackend() *Backend {
 	return &Backend{
-		BufferSize:    DefaultBufferSize,
-		Buffered:      true,
-		nameHierarchy: commonlog.NewNameHierarchy(),
+		BufferSize:     DefaultBufferSize,
+		Buffered:       true,
+		InfoVerbosity:  DefaultInfoVerbosity,
+		DebugVerbosity: DefaultDebugVerbosity,
+		nameHierarchy:  commonlog.NewNameHierarchy(),
 	}
 }
 
@@ -72,15 +115,16 @@ func (self *Backend) Configure(verbosity int, path *string) {
 				util.Failf("log file error: %s", err.Error())
 			}
 		} else if self.Buffered {
-			writer := util.NewBufferedWriter(os.Stderr, self.BufferSize, false)
+			writer := util.NewBufferedWriter(commonlog.Stderr, self.BufferSize, false)
 			util.OnExitError(writer.Close)
 			self.writer = writer
 			klog.SetOutput(writer)
 		} else {
-			klog.SetOutput(util.NewSyncedWriter(os.Stderr))
+			klog.SetOutput(util.NewSyncedWriter(commonlog.Stderr))
 		}
 
 		self.nameHierarchy.SetMaxLevel(maxLevel)
+		self.syncKlogVerbosity()
 	}
 }
 
@@ -91,23 +135,53 @@ func (self *Backend) GetWriter() io.Writer {
 
 // ([commonlog.Backend] interface)
 func (self *Backend) NewMessage(level commonlog.Level, depth int, name ...string) commonlog.Message {
-	if self.AllowLevel(level, name...) {
+	verbosity := self.levelToVerbosity(level)
+
+	allowed := self.AllowLevel(level, name...)
+	if !allowed && (verbosity > 0) && (level != commonlog.None) && isKlogVModuleSet() {
+		allowed = klog.VDepth(depth+1, verbosity).Enabled()
+	}
+
+	if allowed {
 		return commonlog.TraceMessage(commonlog.NewLinearMessage(func(message *commonlog.LinearMessage) {
-			message_ := message.StringWithPrefix(name...)
+			var err error
+			var keysAndValues []any
+
+			if name := strings.Join(name, "."); name != "" {
+				keysAndValues = append(keysAndValues, NameKey, name)
+			}
+
+			if message.Scope != "" {
+				keysAndValues = append(keysAndValues, ScopeKey, message.Scope)
+			}
 
+			for _, value := range message.Values {
+				if value.Key == ErrorKey {
+					if err_, ok := value.Value.(error); ok && (err == nil) {
+						err = err_
+						continue
+					}
+				}
+				keysAndValues = append(keysAndValues, value.Key, value.Value)
+			}
+
+			// Note: depth is relative to this function, which is called by
+			// LinearMessage.Send
 			switch level {
-			case commonlog.Critical:
-				klog.ErrorDepth(depth, message_)
-			case commonlog.Error:
-				klog.ErrorDepth(depth, message_)
+			case commonlog.Critical, commonlog.Error:
+				klog.ErrorSDepth(depth, err, message.Message, keysAndValues...)
 			case commonlog.Warning:
-				klog.WarningDepth(depth, message_)
+				if err != nil {
+					keysAndValues = append(keysAndValues, ErrorKey, err)
+				}
+				klog.WarningDepth(depth, FormatStructured(message.Message, keysAndValues...))
 			case commonlog.Notice:
-				klog.InfoDepth(depth, message_)
-			case commonlog.Info:
-				klog.InfoDepth(depth, message_)
-			case commonlog.Debug:
-				klog.InfoDepth(depth, message_)
+				klog.InfoSDepth(depth, message.Message, keysAndValues...)
+			case commonlog.Info, commonlog.Debug:
+				if err != nil {
+					keysAndValues = append(keysAndValues, ErrorKey, err)
+				}
+				klog.VDepth(depth, verbosity).InfoSDepth(depth, message.Message, keysAndValues...)
 			default:
 				panic(fmt.Sprintf("unsupported log level: %d", level))
 			}
@@ -125,9 +199,111 @@ func (self *Backend) AllowLevel(level commonlog.Level, name ...string) bool {
 // ([commonlog.Backend] interface)
 func (self *Backend) SetMaxLevel(level commonlog.Level, name ...string) {
 	self.nameHierarchy.SetMaxLevel(level, name...)
+	self.syncKlogVerbosity()
 }
 
 // ([commonlog.Backend] interface)
 func (self *Backend) GetMaxLevel(name ...string) commonlog.Level {
 	return self.nameHierarchy.GetMaxLevel(name...)
 }
+
+// Sets the max level for the root name according to klog's "-v" flag. Call
+// this after parsing klog's flags. The flag's value is remembered as the
+// lowest value to which it will be lowered.
+func (self *Backend) SyncFromKlogFlags() {
+	if verbosity, err := getKlogVerbosity(); err == nil {
+		self.verbosityLock.Lock()
+		self.userVerbosity = verbosity
+		self.ownVerbosity = verbosity
+		self.verbosityLock.Unlock()
+
+		var level commonlog.Level
+		switch {
+		case verbosity >= self.DebugVerbosity:
+			level = commonlog.Debug
+		case verbosity >= self.InfoVerbosity:
+			level = commonlog.Info
+		default:
+			level = commonlog.Notice
+		}
+		self.nameHierarchy.SetMaxLevel(level)
+	}
+}
+
+func (self *Backend) levelToVerbosity(level commonlog.Level) klog.Level {
+	switch level {
+	case commonlog.Info:
+		return self.InfoVerbosity
+	case commonlog.Debug:
+		return self.DebugVerbosity
+	default:
+		return 0
+	}
+}
+
+// Sets klog's "-v" flag to the lowest verbosity that would allow the
+// highest max level, but not lower than the user's verbosity.
+func (self *Backend) syncKlogVerbosity() {
+	self.verbosityLock.Lock()
+	defer self.verbosityLock.Unlock()
+
+	if verbosity, err := getKlogVerbosity(); (err == nil) && (verbosity != self.ownVerbosity) {
+		// Changed by the user since we last set it
+		self.userVerbosity = verbosity
+	}
+
+	verbosity := max(self.levelToVerbosity(self.nameHierarchy.GetHighestMaxLevel()), self.userVerbosity)
+	klogFlags().Lookup("v").Value.Set(strconv.Itoa(int(verbosity)))
+	self.ownVerbosity = verbosity
+}
+
+// Formats the message and key-value pairs similarly to klog's InfoS text
+// format.
+func FormatStructured(message string, keysAndValues ...any) string {
+	var builder strings.Builder
+	builder.WriteString(strconv.Quote(message))
+	length := len(keysAndValues)
+	for index := 0; index < length; index += 2 {
+		builder.WriteByte(' ')
+		builder.WriteString(util.ToString(keysAndValues[index]))
+		builder.WriteByte('=')
+		if index+1 < length {
+			switch value := keysAndValues[index+1].(type) {
+			case string:
+				builder.WriteString(strconv.Quote(value))
+			case error:
+				builder.WriteString(strconv.Quote(value.Error()))
+			case fmt.Stringer:
+				builder.WriteString(strconv.Quote(value.String()))
+			default:
+				builder.WriteString(fmt.Sprintf("%+v", value))
+			}
+		} else {
+			builder.WriteString(`"(MISSING)"`)
+		}
+	}
+	return builder.String()
+}
+
+// Utils
+
+var klogFlagSet *flag.FlagSet
+var klogFlagSetOnce sync.Once
+
+// Note: the flag values are shared with klog's own flags.
+func klogFlags() *flag.FlagSet {
+	klogFlagSetOnce.Do(func() {
+		klogFlagSet = flag.NewFlagSet("klog", flag.ContinueOnError)
+		klog.InitFlags(klogFlagSet)
+	})
+	return klogFlagSet
+}
+
+func getKlogVerbosity() (klog.Level, error) {
+	verbosity, err := strconv.Atoi(klogFlags().Lookup("v").Value.String())
+	return klog.Level(verbosity), err
+}
+
+func isKlogVModuleSet() bool {
+	return klogFlags().Lookup("vmodule").Value.String() != ""
+}
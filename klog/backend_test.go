@@ -0,0 +1,47 @@
+package klog
+
+import (
+	"testing"
+
+	"github.com/tliron/commonlog"
+)
+
+func TestSyncKlogVerbosity(t *testing.T) {
+	backend := NewBackend()
+	defer backend.Configure(0, nil)
+	defer klogFlags().Set("v", "0")
+
+	expectVerbosity := func(expected string) {
+		t.Helper()
+		if verbosity := klogFlags().Lookup("v").Value.String(); verbosity != expected {
+			t.Errorf("-v = %s, expected %s", verbosity, expected)
+		}
+	}
+
+	backend.Configure(0, nil)
+	expectVerbosity("0")
+
+	// Raised and lowered according to our needs
+	backend.SetMaxLevel(commonlog.Debug, "a")
+	expectVerbosity("4")
+	backend.SetMaxLevel(commonlog.Notice, "a")
+	expectVerbosity("0")
+
+	// Never lowered below the user's verbosity
+	klogFlags().Set("v", "6")
+	backend.SyncFromKlogFlags()
+	if level := backend.GetMaxLevel(); level != commonlog.Debug {
+		t.Errorf("max level = %s, expected %s", level, commonlog.Debug)
+	}
+	backend.SetMaxLevel(commonlog.Info)
+	expectVerbosity("6")
+	backend.Configure(0, nil)
+	expectVerbosity("6")
+
+	// Also when set without SyncFromKlogFlags
+	klogFlags().Set("v", "3")
+	backend.SetMaxLevel(commonlog.Debug, "a")
+	expectVerbosity("4")
+	backend.SetMaxLevel(commonlog.Notice, "a")
+	expectVerbosity("3")
+}
@@ -0,0 +1,355 @@
+package commonlog
+
+import (
+	"encoding/json"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/tliron/go-kutil/util"
+)
+
+// Parses a line of text into a message. Returns nil if the line should be
+// skipped (including if the message's level is not loggable).
+type LineParseFunc func(line string) Message
+
+// Creates a [LineParseFunc] that creates messages on the logger. The level
+// is used for lines that do not specify their own.
+type LineParserFunc func(log Logger, level Level) LineParseFunc
+
+// Creates a [LineParseFunc] that uses the entire line as the message text.
+// Control characters are escaped (see [EscapeControlCharacters]).
+//
+// ([LineParserFunc] signature)
+func PlainLineParser(log Logger, level Level) LineParseFunc {
+	return func(line string) Message {
+		if message := log.NewMessage(level, 1); message != nil {
+			message.Set(MESSAGE, EscapeControlCharacters(line))
+			return message
+		} else {
+			return nil
+		}
+	}
+}
+
+// Creates a [LineParseFunc] that detects the level from a level word at
+// the beginning of the line (see [ParseLevelPrefix]), which is removed from
+// the message text. Lines without a level word use the level argument.
+// Control characters are escaped (see [EscapeControlCharacters]).
+//
+// ([LineParserFunc] signature)
+func LevelLineParser(log Logger, level Level) LineParseFunc {
+	return func(line string) Message {
+		level_ := level
+		if level__, rest, ok := ParseLevelPrefix(line); ok {
+			level_ = level__
+			line = rest
+		}
+
+		if message := log.NewMessage(level_, 1); message != nil {
+			message.Set(MESSAGE, EscapeControlCharacters(line))
+			return message
+		} else {
+			return nil
+		}
+	}
+}
+
+const (
+	JSONLevelKey = "_level"
+	JSONNameKey  = "_name"
+)
+
+// Creates a [LineParseFunc] for lines that are JSON objects.
+//
+// Objects that have a "_level" key are in commonlog's own format (as
+// written by the remote backend), in which the metadata keys all begin
+// with a single underscore: "_level", "_name", "_message", "_time",
+// "_scope", "_file", and "_line". All other keys are set as is on the
+// message, except for keys beginning with two underscores, which are
+// unescaped (see [EscapeJSONKey]). This ensures that user keys cannot be
+// confused with the metadata.
+//
+// For other objects, such as those written by third-party loggers, the
+// "level" (or "severity"), "name" (or "logger"), "message" (or "msg"), and
+// "time" keys are specially handled, while all other keys are set as is on
+// the message.
+//
+// Either way, the name can be either a dot-separated string or an array of
+// strings and replaces the name of the logger. Control characters in the
+// name, keys, and values are escaped (see
+// [EscapeControlCharactersInKeyValue]).
+//
+// Lines that are not JSON objects are handled by [PlainLineParser].
+//
+// ([LineParserFunc] signature)
+func JSONLineParser(log Logger, level Level) LineParseFunc {
+	plain := PlainLineParser(log, level)
+
+	return func(line string) Message {
+		trimmed := strings.TrimSpace(line)
+		if !strings.HasPrefix(trimmed, "{") {
+			return plain(line)
+		}
+
+		var object map[string]any
+		if err := json.Unmarshal(util.StringToBytes(trimmed), &object); err != nil {
+			return plain(line)
+		}
+
+		keys := make([]string, 0, len(object))
+		for key := range object {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+
+		_, native := object[JSONLevelKey]
+
+		log_ := log
+		level_ := level
+		var message_ any
+		var keysAndValues []any
+		for _, key := range keys {
+			value := object[key]
+
+			var field string
+			if native {
+				if strings.HasPrefix(key, "__") {
+					// Escaped user key
+					key_, value_ := EscapeControlCharactersInKeyValue(key[1:], value)
+					keysAndValues = append(keysAndValues, key_, value_)
+					continue
+				}
+
+				switch key {
+				case JSONLevelKey, JSONNameKey, MESSAGE, TIME:
+					field = key
+				}
+			} else {
+				switch key {
+				case "level", "severity":
+					field = JSONLevelKey
+				case "name", "logger":
+					field = JSONNameKey
+				case "message", "msg", MESSAGE:
+					field = MESSAGE
+				case "time", TIME:
+					field = TIME
+				}
+			}
+
+			switch field {
+			case JSONLevelKey:
+				if level__, ok := ParseLevel(util.ToString(value)); ok {
+					level_ = level__
+				}
+
+			case JSONNameKey:
+				var name []string
+				switch value_ := value.(type) {
+				case string:
+					name = PathToName(value_)
+				case []any:
+					name = util.ToStrings(value_)
+				}
+				if name != nil {
+					for index, segment := range name {
+						name[index] = EscapeControlCharacters(segment)
+					}
+					log_ = withName(log, name...)
+				}
+
+			case MESSAGE:
+				message_ = value
+
+			case TIME:
+				keysAndValues = append(keysAndValues, TIME, escapeControlCharactersInValue(value))
+
+			default:
+				key_, value_ := EscapeControlCharactersInKeyValue(key, value)
+				keysAndValues = append(keysAndValues, key_, value_)
+			}
+		}
+
+		if message := log_.NewMessage(level_, 1); message != nil {
+			if message_ != nil {
+				message.Set(MESSAGE, EscapeControlCharacters(util.ToString(message_)))
+			}
+			SetMessageKeysAndValues(message, keysAndValues...)
+			return message
+		} else {
+			return nil
+		}
+	}
+}
+
+// Escapes a user key for commonlog's JSON format (see [JSONLineParser]) by
+// adding an underscore to keys that begin with an underscore, so that they
+// cannot be confused with the metadata keys.
+func EscapeJSONKey(key string) string {
+	if strings.HasPrefix(key, "_") {
+		return "_" + key
+	} else {
+		return key
+	}
+}
+
+// Creates a [LineParseFunc] for lines that are RFC 5424 or RFC 3164 syslog
+// messages (see [ParseSyslogMessage] and [SyslogMessage.NewMessage]). The
+// level argument is ignored.
+//
+// Lines that are not syslog messages are handled by [PlainLineParser].
+//
+// ([LineParserFunc] signature)
+func SyslogLineParser(log Logger, level Level) LineParseFunc {
+	plain := PlainLineParser(log, level)
+
+	return func(line string) Message {
+		if syslogMessage, err := ParseSyslogMessage(line); err == nil {
+			return syslogMessage.NewMessage(log)
+		} else {
+			return plain(line)
+		}
+	}
+}
+
+// Parses level names case-insensitively. Supports common aliases, such as
+// "warn", "err", and "trace".
+func ParseLevel(name string) (Level, bool) {
+	switch strings.ToLower(name) {
+	case "none", "off", "disabled":
+		return None, true
+	case "critical", "crit", "fatal", "panic", "emergency", "emerg", "alert":
+		return Critical, true
+	case "error", "err":
+		return Error, true
+	case "warning", "warn":
+		return Warning, true
+	case "notice", "note":
+		return Notice, true
+	case "info", "informational":
+		return Info, true
+	case "debug", "trace":
+		return Debug, true
+	default:
+		return None, false
+	}
+}
+
+// Detects a level word (see [ParseLevel]) at the beginning of the text and
+// returns the level and the rest of the text after it. To avoid confusion
+// with ordinary words the level word must either be bracketed, e.g.
+// "[WARN]" or "<info>", followed by a colon, e.g. "warning:", or in
+// uppercase, e.g. "ERROR".
+func ParseLevelPrefix(text string) (Level, string, bool) {
+	trimmed := strings.TrimLeft(text, " \t")
+	word, rest, _ := strings.Cut(trimmed, " ")
+
+	var name string
+	switch {
+	case (len(word) > 2) && (word[0] == '[') && (word[len(word)-1] == ']'),
+		(len(word) > 2) && (word[0] == '<') && (word[len(word)-1] == '>'):
+		name = word[1 : len(word)-1]
+
+	case (len(word) > 1) && (word[len(word)-1] == ':'):
+		name = word[:len(word)-1]
+		if (len(name) > 2) && (name[0] == '[') && (name[len(name)-1] == ']') {
+			name = name[1 : len(name)-1]
+		}
+
+	case strings.ToUpper(word) == word:
+		name = word
+
+	default:
+		return None, text, false
+	}
+
+	if level, ok := ParseLevel(name); ok && (level != None) {
+		return level, strings.TrimLeft(rest, " \t"), true
+	}
+
+	return None, text, false
+}
+
+// Escapes control characters (other than tab) using Go escape sequences,
+// e.g. "\n" and "\x1b", thus making sure that text received from external
+// sources cannot forge log lines or inject terminal escape codes.
+func EscapeControlCharacters(text string) string {
+	if strings.IndexFunc(text, isEscapedControlCharacter) == -1 {
+		return text
+	}
+
+	var builder strings.Builder
+	for _, r := range text {
+		if isEscapedControlCharacter(r) {
+			switch r {
+			case '\n':
+				builder.WriteString(`\n`)
+			case '\r':
+				builder.WriteString(`\r`)
+			default:
+				quoted := strconv.QuoteRune(r)
+				builder.WriteString(quoted[1 : len(quoted)-1])
+			}
+		} else {
+			builder.WriteRune(r)
+		}
+	}
+	return builder.String()
+}
+
+// Escapes control characters (see [EscapeControlCharacters]) in a key and
+// in its value. Nested maps and slices are escaped recursively, including
+// the keys of the maps.
+func EscapeControlCharactersInKeyValue(key string, value any) (string, any) {
+	return EscapeControlCharacters(key), escapeControlCharactersInValue(value)
+}
+
+// Utils
+
+func isEscapedControlCharacter(r rune) bool {
+	return (r != '\t') && unicode.IsControl(r)
+}
+
+func escapeControlCharactersInValue(value any) any {
+	switch value_ := value.(type) {
+	case string:
+		return EscapeControlCharacters(value_)
+
+	case map[string]any:
+		map_ := make(map[string]any, len(value_))
+		for key, value__ := range value_ {
+			key, value__ = EscapeControlCharactersInKeyValue(key, value__)
+			map_[key] = value__
+		}
+		return map_
+
+	case []any:
+		for index, value__ := range value_ {
+			value_[index] = escapeControlCharactersInValue(value__)
+		}
+		return value_
+
+	default:
+		return value
+	}
+}
+
+// Returns a logger for the name that keeps the keys and values of the
+// original logger.
+func withName(log Logger, name ...string) Logger {
+	switch log_ := log.(type) {
+	case KeyValueLogger:
+		return KeyValueLogger{
+			logger:        withName(log_.logger, name...),
+			keysAndValues: log_.keysAndValues,
+		}
+
+	case BackendLogger:
+		return NewBackendLogger(name...)
+
+	default:
+		return log
+	}
+}
@@ -0,0 +1,76 @@
+package commonlog_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/tliron/commonlog"
+	"github.com/tliron/commonlog/internal/recording"
+)
+
+func TestJSONLineParserEscaping(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		logger  []string
+		message string
+		values  map[string]any
+	}{
+		{
+			name:   "newline in key",
+			line:   `{"a\nNOTICE forged":1}`,
+			logger: []string{"server"},
+			values: map[string]any{`a\nNOTICE forged`: float64(1)},
+		},
+		{
+			name:   "newline in nested key",
+			line:   `{"a":{"b\nforged":"c\u001b[31m"}}`,
+			logger: []string{"server"},
+			values: map[string]any{"a": map[string]any{`b\nforged`: `c\x1b[31m`}},
+		},
+		{
+			name:    "newline in name",
+			line:    `{"name":"x\nforged","msg":"hello\nworld"}`,
+			logger:  []string{`x\nforged`},
+			message: `hello\nworld`,
+			values:  map[string]any{},
+		},
+		{
+			name:   "newline in name array",
+			line:   `{"logger":["x","y\rforged"]}`,
+			logger: []string{"x", `y\rforged`},
+			values: map[string]any{},
+		},
+		{
+			name:   "newline in native name and escaped key",
+			line:   `{"_level":"info","_name":"x\nforged","__a\nforged":1}`,
+			logger: []string{`x\nforged`},
+			values: map[string]any{`_a\nforged`: float64(1)},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			backend := recording.Use(t)
+
+			parse := commonlog.JSONLineParser(commonlog.GetLogger("server"), commonlog.Info)
+			parse(test.line).Send()
+
+			messages := backend.GetMessages()
+			if len(messages) != 1 {
+				t.Fatalf("got %d messages, expected 1", len(messages))
+			}
+
+			message := messages[0]
+			if !reflect.DeepEqual(message.Name, test.logger) {
+				t.Errorf("name = %q, expected %q", message.Name, test.logger)
+			}
+			if message.Message.Message != test.message {
+				t.Errorf("message = %q, expected %q", message.Message.Message, test.message)
+			}
+			if values := message.GetValues(); !reflect.DeepEqual(values, test.values) {
+				t.Errorf("values = %#v, expected %#v", values, test.values)
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package commonlog
 
 import (
 	"fmt"
+	"io"
 )
 
 //
@@ -59,6 +60,11 @@ func (self BackendLogger) Logf(level Level, depth int, format string, args ...an
 	}
 }
 
+// ([Logger] interface)
+func (self BackendLogger) Writer(level Level) io.WriteCloser {
+	return NewLoggerWriter(self, level)
+}
+
 // ([Logger] interface)
 func (self BackendLogger) Critical(message string, keysAndValues ...any) {
 	self.Log(Critical, 1, message, keysAndValues...)
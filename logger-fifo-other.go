@@ -17,6 +17,13 @@ func NewLoggerFIFO(prefix string, log Logger, level Level) *LoggerFIFO {
 	return new(LoggerFIFO)
 }
 
+func NewLoggerFIFOInDirectory(directory string, prefix string, log Logger, level Level) *LoggerFIFO {
+	return new(LoggerFIFO)
+}
+
 func (self *LoggerFIFO) Start() error {
 	return errors.New("not supported on this platform")
 }
+
+func (self *LoggerFIFO) Stop() {
+}
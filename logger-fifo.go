@@ -4,35 +4,64 @@ package commonlog
 
 import (
 	"bufio"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"sync"
+	"sync/atomic"
 	"syscall"
+	"time"
 
 	"github.com/segmentio/ksuid"
 )
 
+const DefaultFIFOPermissions = 0600
+
 //
 // LoggerFIFO
 //
 
 // A Linux FIFO file that forwards all lines written to it to a [Logger].
+//
+// Lines are parsed by the Parser, which defaults to [PlainLineParser]. Use
+// [LevelLineParser] to detect per-line levels.
+//
+// By default the FIFO is removed after its first writer closes it. When
+// Persistent is true it is instead reopened for the next writer, until
+// [LoggerFIFO.Stop] is called.
 type LoggerFIFO struct {
-	Path  string
-	Log   Logger
-	Level Level
+	Path        string
+	Log         Logger
+	Level       Level
+	Parser      LineParserFunc
+	Permissions fs.FileMode
+	Persistent  bool
+
+	file     *os.File
+	fileLock sync.Mutex
+	stopped  atomic.Bool
+	done     chan struct{}
 }
 
+// Creates the FIFO in [os.TempDir].
 func NewLoggerFIFO(prefix string, log Logger, level Level) *LoggerFIFO {
-	path := filepath.Join(os.TempDir(), prefix+ksuid.New().String())
+	return NewLoggerFIFOInDirectory(os.TempDir(), prefix, log, level)
+}
+
+func NewLoggerFIFOInDirectory(directory string, prefix string, log Logger, level Level) *LoggerFIFO {
+	path := filepath.Join(directory, prefix+ksuid.New().String())
 	return &LoggerFIFO{
-		Path:  path,
-		Log:   NewKeyValueLogger(log, "fifo", path),
-		Level: level,
+		Path:        path,
+		Log:         NewKeyValueLogger(log, "fifo", path),
+		Level:       level,
+		Parser:      PlainLineParser,
+		Permissions: DefaultFIFOPermissions,
 	}
 }
 
 func (self *LoggerFIFO) Start() error {
 	if err := self.create(); err == nil {
+		self.done = make(chan struct{})
 		go self.start()
 		return nil
 	} else {
@@ -40,6 +69,40 @@ func (self *LoggerFIFO) Start() error {
 	}
 }
 
+// Closes the FIFO, even if it is waiting for a writer, and removes it.
+func (self *LoggerFIFO) Stop() {
+	if self.stopped.Swap(true) {
+		return
+	}
+
+	if self.done == nil {
+		// Never started
+		self.remove()
+		return
+	}
+
+	self.fileLock.Lock()
+	if self.file != nil {
+		self.file.Close()
+	}
+	self.fileLock.Unlock()
+
+	// os.Open will block until the FIFO will be opened for write, so we
+	// will open it ourselves (we might need to retry if it's not yet
+	// opened for read)
+	for {
+		if writer, err := os.OpenFile(self.Path, os.O_WRONLY|syscall.O_NONBLOCK, 0); err == nil {
+			writer.Close()
+		}
+
+		select {
+		case <-self.done:
+			return
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
 func (self *LoggerFIFO) create() error {
 	if err := os.Remove(self.Path); err != nil {
 		if !os.IsNotExist(err) {
@@ -47,29 +110,82 @@ func (self *LoggerFIFO) create() error {
 		}
 	}
 	self.Log.Debug("creating logger FIFO")
-	return syscall.Mkfifo(self.Path, 0600)
+
+	permissions := self.Permissions
+	if permissions == 0 {
+		permissions = DefaultFIFOPermissions
+	}
+	if err := syscall.Mkfifo(self.Path, uint32(permissions)); err != nil {
+		return err
+	}
+
+	// Mkfifo is affected by umask
+	return os.Chmod(self.Path, permissions)
 }
 
 func (self *LoggerFIFO) start() {
-	// Note: os.Open will block until the FIFO will be opened for write
-	if file, err := os.Open(self.Path); err == nil {
+	defer close(self.done)
+	defer self.remove()
+
+	parser := self.Parser
+	if parser == nil {
+		parser = PlainLineParser
+	}
+	parse := parser(self.Log, self.Level)
+
+	for {
+		// Note: os.Open will block until the FIFO will be opened for write
+		file, err := os.Open(self.Path)
+		if err != nil {
+			if !self.stopped.Load() {
+				self.Log.Critical(err.Error())
+			}
+			return
+		}
+
+		if !self.setFile(file) {
+			return
+		}
+
 		scanner := bufio.NewScanner(file)
 		for scanner.Scan() {
-			self.Log.Log(self.Level, 0, scanner.Text())
+			if message := parse(scanner.Text()); message != nil {
+				message.Send()
+			}
 		}
 
-		if err := scanner.Err(); err != nil {
+		if err := scanner.Err(); (err != nil) && !self.stopped.Load() {
 			self.Log.Error(err.Error())
 		}
 
 		self.Log.Debug("closing logger FIFO")
-		if err := file.Close(); err != nil {
+		self.setFile(nil)
+		if err := file.Close(); (err != nil) && !self.stopped.Load() {
 			self.Log.Error(err.Error())
 		}
-		if err := os.Remove(self.Path); err != nil {
-			self.Log.Error(err.Error())
+
+		if !self.Persistent || self.stopped.Load() {
+			return
 		}
-	} else {
-		self.Log.Critical(err.Error())
+	}
+}
+
+// Returns false (and closes the file) if we were stopped.
+func (self *LoggerFIFO) setFile(file *os.File) bool {
+	self.fileLock.Lock()
+	defer self.fileLock.Unlock()
+
+	if (file != nil) && self.stopped.Load() {
+		file.Close()
+		return false
+	}
+
+	self.file = file
+	return true
+}
+
+func (self *LoggerFIFO) remove() {
+	if err := os.Remove(self.Path); (err != nil) && !os.IsNotExist(err) {
+		self.Log.Error(err.Error())
 	}
 }
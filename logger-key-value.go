@@ -2,6 +2,7 @@ package commonlog
 
 import (
 	"fmt"
+	"io"
 
 	"github.com/tliron/go-kutil/util"
 )
@@ -100,6 +101,11 @@ func (self KeyValueLogger) Logf(level Level, depth int, format string, args ...a
 	}
 }
 
+// ([Logger] interface)
+func (self KeyValueLogger) Writer(level Level) io.WriteCloser {
+	return NewLoggerWriter(self, level)
+}
+
 // ([Logger] interface)
 func (self KeyValueLogger) Critical(message string, keysAndValues ...any) {
 	self.Log(Critical, 1, message, keysAndValues...)
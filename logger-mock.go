@@ -1,5 +1,9 @@
 package commonlog
 
+import (
+	"io"
+)
+
 //
 // MockLogger
 //
@@ -36,6 +40,11 @@ func (self MockLogger) Log(level Level, depth int, message string, keysAndValues
 func (self MockLogger) Logf(level Level, depth int, format string, args ...any) {
 }
 
+// ([Logger] interface)
+func (self MockLogger) Writer(level Level) io.WriteCloser {
+	return NewLoggerWriter(self, level)
+}
+
 // ([Logger] interface)
 func (self MockLogger) Critical(message string, keysAndValues ...any) {
 }
@@ -0,0 +1,219 @@
+package commonlog
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"strings"
+
+	"github.com/tliron/go-kutil/util"
+)
+
+// Maximum size of a syslog message. UDP datagrams cannot be larger.
+const SyslogMaxMessageSize = 65536
+
+// Enough for [SyslogMaxMessageSize].
+const syslogMaxOctetCountDigits = 5
+
+//
+// SyslogServer
+//
+
+// A UDP and TCP server that parses RFC 5424 and RFC 3164 syslog messages
+// (see [ParseSyslogMessage]) and forwards them to a [Logger] (see
+// [SyslogMessage.NewMessage]).
+//
+// TCP supports both octet-counting and newline framing, detected per
+// message.
+type SyslogServer struct {
+	IPStack util.IPStack
+	Address string
+	Port    int
+	Log     Logger
+
+	ClientAddressPorts []string
+
+	listeners   []net.Listener
+	packetConns []net.PacketConn
+}
+
+func NewSyslogServer(ipStack util.IPStack, address string, port int, log Logger) *SyslogServer {
+	return &SyslogServer{
+		IPStack: ipStack,
+		Address: address,
+		Port:    port,
+		Log:     NewKeyValueLogger(log, "syslog", port),
+	}
+}
+
+func (self *SyslogServer) Start() error {
+	return self.IPStack.StartServers(self.Address, self.start)
+}
+
+func (self *SyslogServer) Stop() {
+	for index, listener := range self.listeners {
+		self.Log.Notice("stopping syslog TCP server",
+			"index", index)
+		if err := listener.Close(); err != nil {
+			self.Log.Error(err.Error())
+		}
+		self.Log.Notice("stopped syslog TCP server",
+			"index", index)
+	}
+
+	for index, packetConn := range self.packetConns {
+		self.Log.Notice("stopping syslog UDP server",
+			"index", index)
+		if err := packetConn.Close(); err != nil {
+			self.Log.Error(err.Error())
+		}
+		self.Log.Notice("stopped syslog UDP server",
+			"index", index)
+	}
+}
+
+// ([util.IPStackStartServerFunc] signature)
+func (self *SyslogServer) start(level2protocol string, address string) error {
+	if address, err := util.ToReachableIPAddress(address); err == nil {
+		addressPort := util.JoinIPAddressPort(address, self.Port)
+
+		listener, err := net.Listen(level2protocol, addressPort)
+		if err != nil {
+			return err
+		}
+
+		// "tcp", "tcp4", "tcp6" -> "udp", "udp4", "udp6"
+		udpProtocol := "udp" + strings.TrimPrefix(level2protocol, "tcp")
+		packetConn, err := net.ListenPacket(udpProtocol, addressPort)
+		if err != nil {
+			listener.Close()
+			return err
+		}
+
+		self.Log.Notice("starting syslog server",
+			"index", len(self.listeners),
+			"level2protocol", level2protocol,
+			"addressPort", listener.Addr().String())
+
+		self.ClientAddressPorts = append(self.ClientAddressPorts, util.IPAddressPortWithoutZone(addressPort))
+		self.listeners = append(self.listeners, listener)
+		self.packetConns = append(self.packetConns, packetConn)
+
+		go func() {
+			for {
+				if conn, err := listener.Accept(); err == nil {
+					self.Log.Debug("accepted syslog server connection")
+					go self.handle(conn)
+				} else {
+					if !errors.Is(err, net.ErrClosed) {
+						self.Log.Critical(err.Error())
+					}
+					return
+				}
+			}
+		}()
+
+		go self.receive(packetConn)
+
+		return nil
+	} else {
+		return err
+	}
+}
+
+func (self *SyslogServer) receive(packetConn net.PacketConn) {
+	buffer := make([]byte, SyslogMaxMessageSize)
+	for {
+		if count, _, err := packetConn.ReadFrom(buffer); err == nil {
+			self.forward(string(buffer[:count]))
+		} else {
+			if !errors.Is(err, net.ErrClosed) {
+				self.Log.Critical(err.Error())
+			}
+			return
+		}
+	}
+}
+
+func (self *SyslogServer) handle(conn net.Conn) {
+	defer CallAndLogError(func() error {
+		self.Log.Debug("closing syslog server connection")
+		return conn.Close()
+	}, "Conn.Close", self.Log)
+
+	// Note: the buffer size limits the length of newline-framed messages
+	reader := bufio.NewReaderSize(conn, SyslogMaxMessageSize)
+	for {
+		if text, err := readSyslogFrame(reader); err == nil {
+			self.forward(text)
+		} else {
+			if err != io.EOF {
+				self.Log.Error(err.Error())
+			}
+			return
+		}
+	}
+}
+
+func (self *SyslogServer) forward(text string) {
+	if message, err := ParseSyslogMessage(text); err == nil {
+		if message_ := message.NewMessage(self.Log); message_ != nil {
+			message_.Send()
+		}
+	} else {
+		self.Log.Warning(err.Error())
+	}
+}
+
+// Octet-counting framing starts with a digit, while a syslog message
+// always starts with "<".
+func readSyslogFrame(reader *bufio.Reader) (string, error) {
+	for {
+		first, err := reader.Peek(1)
+		if err != nil {
+			return "", err
+		}
+
+		if (first[0] >= '0') && (first[0] <= '9') {
+			length := 0
+			for digits := 0; ; digits++ {
+				digit, err := reader.ReadByte()
+				if err != nil {
+					return "", err
+				}
+
+				if (digit == ' ') && (digits > 0) {
+					break
+				}
+
+				if (digit < '0') || (digit > '9') || (digits == syslogMaxOctetCountDigits) {
+					return "", errors.New("malformed syslog octet count")
+				}
+
+				length = length*10 + int(digit-'0')
+			}
+
+			if length > SyslogMaxMessageSize {
+				return "", errors.New("malformed syslog octet count")
+			}
+
+			buffer := make([]byte, length)
+			if _, err := io.ReadFull(reader, buffer); err == nil {
+				return string(buffer), nil
+			} else {
+				return "", err
+			}
+		}
+
+		// Note: longer messages are truncated
+		text, _, err := ReadLine(reader)
+		if text != "" {
+			return text, nil
+		}
+		if err != nil {
+			return "", err
+		}
+		// Skip empty lines
+	}
+}
@@ -0,0 +1,83 @@
+package commonlog
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadSyslogFrame(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		frames []string
+		err    error
+	}{
+		{
+			name:   "octet counting",
+			input:  "5 <1>ab5 <2>cd",
+			frames: []string{"<1>ab", "<2>cd"},
+			err:    io.EOF,
+		},
+		{
+			name:   "newline framing",
+			input:  "<1>ab\r\n\n<2>cd\n",
+			frames: []string{"<1>ab", "<2>cd"},
+			err:    io.EOF,
+		},
+		{
+			name:   "mixed",
+			input:  "5 <1>ab<2>cd\n",
+			frames: []string{"<1>ab", "<2>cd"},
+			err:    io.EOF,
+		},
+		{
+			name:   "truncated newline framing",
+			input:  strings.Repeat("a", SyslogMaxMessageSize+10) + "\n<2>cd\n",
+			frames: []string{strings.Repeat("a", SyslogMaxMessageSize), "<2>cd"},
+			err:    io.EOF,
+		},
+		{
+			name:  "octet count without space",
+			input: strings.Repeat("1", 100),
+			err:   errors.New("malformed syslog octet count"),
+		},
+		{
+			name:  "octet count too large",
+			input: "99999 <1>ab",
+			err:   errors.New("malformed syslog octet count"),
+		},
+		{
+			name:  "incomplete octet-counted message",
+			input: "10 <1>ab",
+			err:   io.ErrUnexpectedEOF,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reader := bufio.NewReaderSize(strings.NewReader(test.input), SyslogMaxMessageSize)
+
+			var frames []string
+			var err error
+			for {
+				var frame string
+				if frame, err = readSyslogFrame(reader); err == nil {
+					frames = append(frames, frame)
+				} else {
+					break
+				}
+			}
+
+			if !reflect.DeepEqual(frames, test.frames) {
+				t.Errorf("frames = %.40q, expected %.40q", frames, test.frames)
+			}
+			if err.Error() != test.err.Error() {
+				t.Errorf("err = %s, expected %s", err, test.err)
+			}
+		})
+	}
+}
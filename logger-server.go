@@ -2,51 +2,164 @@ package commonlog
 
 import (
 	"bufio"
+	contextpkg "context"
+	"crypto/subtle"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
 	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/tliron/go-kutil/util"
 )
 
+const (
+	DefaultMaxLineLength    = 65536
+	DefaultHandshakeTimeout = 10 * time.Second
+	DefaultUnixPermissions  = 0600
+)
+
 //
 // LoggerServer
 //
 
-// A TCP server file that forwards all lines written to it to a [Logger].
+// A server that forwards all lines written to it to a [Logger].
+//
+// It listens on TCP by default, and optionally on UDP on the same address
+// and port. It can also listen on a unix socket at UnixPath, either a
+// stream socket or (when UnixDatagram is true) a datagram socket. For UDP
+// and unix datagram sockets each datagram is a single line. The unix
+// socket is accessible only with UnixPermissions.
+//
+// Lines are parsed by the Parser, which defaults to [PlainLineParser].
+// Each connection's logger has a "remoteAddress" key.
+//
+// When TLSConfig is set connections must use TLS. Client certificates
+// can be verified by setting its ClientAuth and ClientCAs. The TLS
+// handshake must complete within HandshakeTimeout.
+//
+// When Token is set the first line sent on each connection must equal it
+// (within HandshakeTimeout), otherwise the connection is closed. Datagrams
+// must likewise start with a line containing the token, otherwise they are
+// dropped.
+//
+// Lines longer than MaxLineLength are truncated and will have a
+// "truncated" key. MaxConnections and MaxLinesPerSecond (per connection)
+// are unlimited when 0. Exceeding connections are closed immediately,
+// while exceeding lines are throttled. Datagrams are not rate limited.
 type LoggerServer struct {
-	IPStack util.IPStack
-	Address string
-	Port    int
-	Log     Logger
-	Level   Level
+	IPStack           util.IPStack
+	Address           string
+	Port              int
+	Log               Logger
+	Level             Level
+	Parser            LineParserFunc
+	TLSConfig         *tls.Config
+	Token             string
+	HandshakeTimeout  time.Duration
+	MaxConnections    int
+	MaxLinesPerSecond float64
+	MaxLineLength     int
+	TCP               bool
+	UDP               bool
+	UnixPath          string
+	UnixDatagram      bool
+	UnixPermissions   fs.FileMode
 
 	ClientAddressPorts []string
 
-	listeners []net.Listener
+	listeners    []net.Listener
+	packetConns  []net.PacketConn
+	conns        map[net.Conn]struct{}
+	connsLock    sync.Mutex
+	shuttingDown bool
+	handlers     sync.WaitGroup
 }
 
 func NewLoggerServer(ipStack util.IPStack, address string, port int, log Logger, level Level) *LoggerServer {
 	return &LoggerServer{
-		IPStack: ipStack,
-		Address: address,
-		Port:    port,
-		Log:     NewKeyValueLogger(log, "tcp", port),
-		Level:   level,
+		IPStack:          ipStack,
+		Address:          address,
+		Port:             port,
+		Log:              NewKeyValueLogger(log, "tcp", port),
+		Level:            level,
+		Parser:           PlainLineParser,
+		HandshakeTimeout: DefaultHandshakeTimeout,
+		MaxLineLength:    DefaultMaxLineLength,
+		TCP:              true,
+		UnixPermissions:  DefaultUnixPermissions,
+		conns:            make(map[net.Conn]struct{}),
+	}
+}
+
+// Creates a [LoggerServer] that listens only on a unix socket.
+func NewUnixLoggerServer(path string, datagram bool, log Logger, level Level) *LoggerServer {
+	return &LoggerServer{
+		Log:              NewKeyValueLogger(log, "unix", path),
+		Level:            level,
+		Parser:           PlainLineParser,
+		HandshakeTimeout: DefaultHandshakeTimeout,
+		MaxLineLength:    DefaultMaxLineLength,
+		UnixPath:         path,
+		UnixDatagram:     datagram,
+		UnixPermissions:  DefaultUnixPermissions,
+		conns:            make(map[net.Conn]struct{}),
 	}
 }
 
 func (self *LoggerServer) Start() error {
-	return self.IPStack.StartServers(self.Address, self.start)
+	self.connsLock.Lock()
+	self.shuttingDown = false
+	self.connsLock.Unlock()
+
+	if self.TCP || self.UDP {
+		if err := self.IPStack.StartServers(self.Address, self.start); err != nil {
+			return err
+		}
+	}
+
+	if self.UnixPath != "" {
+		return self.startUnix()
+	}
+
+	return nil
 }
 
+// Closes the listeners as well as all active connections and waits for
+// their handlers to finish.
 func (self *LoggerServer) Stop() {
-	for index, listener := range self.listeners {
-		self.Log.Notice("stopping logger server",
-			"index", index)
-		if err := listener.Close(); err != nil {
-			self.Log.Error(err.Error())
-		}
-		self.Log.Notice("stopped logger server",
-			"index", index)
+	self.closeListeners()
+	self.closeConns()
+	self.handlers.Wait()
+}
+
+// Closes the listeners and then waits for active connections to be
+// closed by their clients. If the context is done before that happens
+// then the remaining connections are closed and the context's error is
+// returned.
+func (self *LoggerServer) Shutdown(context contextpkg.Context) error {
+	self.closeListeners()
+
+	drained := make(chan struct{})
+	go func() {
+		self.handlers.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		return nil
+
+	case <-context.Done():
+		self.closeConns()
+		<-drained
+		return context.Err()
 	}
 }
 
@@ -54,48 +167,479 @@ func (self *LoggerServer) Stop() {
 func (self *LoggerServer) start(level2protocol string, address string) error {
 	if address, err := util.ToReachableIPAddress(address); err == nil {
 		addressPort := util.JoinIPAddressPort(address, self.Port)
-		if listener, err := net.Listen(level2protocol, addressPort); err == nil {
-			self.Log.Notice("starting logger server",
-				"index", len(self.listeners),
-				"level2protocol", level2protocol,
-				"addressPort", listener.Addr().String())
 
-			self.ClientAddressPorts = append(self.ClientAddressPorts, util.IPAddressPortWithoutZone(addressPort))
-			self.listeners = append(self.listeners, listener)
+		if self.TCP {
+			if listener, err := net.Listen(level2protocol, addressPort); err == nil {
+				self.Log.Notice("starting logger server",
+					"index", len(self.listeners),
+					"level2protocol", level2protocol,
+					"addressPort", listener.Addr().String(),
+					"tls", self.TLSConfig != nil)
 
-			go func() {
-				for {
-					if conn, err := listener.Accept(); err == nil {
-						self.Log.Debug("accepted logger server connection")
-						go self.handle(conn)
-					} else {
-						self.Log.Critical(err.Error())
-						return
-					}
+				if self.TLSConfig != nil {
+					listener = tls.NewListener(listener, self.TLSConfig)
 				}
-			}()
 
+				self.listeners = append(self.listeners, listener)
+				go self.accept(listener)
+			} else {
+				return err
+			}
+		}
+
+		if self.UDP {
+			// "tcp", "tcp4", "tcp6" -> "udp", "udp4", "udp6"
+			udpProtocol := "udp" + strings.TrimPrefix(level2protocol, "tcp")
+			if packetConn, err := net.ListenPacket(udpProtocol, addressPort); err == nil {
+				self.Log.Notice("starting logger server",
+					"index", len(self.packetConns),
+					"level2protocol", udpProtocol,
+					"addressPort", packetConn.LocalAddr().String())
+
+				self.packetConns = append(self.packetConns, packetConn)
+				self.handlers.Add(1)
+				go self.receive(packetConn)
+			} else {
+				return err
+			}
+		}
+
+		self.ClientAddressPorts = append(self.ClientAddressPorts, util.IPAddressPortWithoutZone(addressPort))
+
+		return nil
+	} else {
+		return err
+	}
+}
+
+func (self *LoggerServer) startUnix() error {
+	// Remove a stale socket file (but nothing else)
+	if info, err := os.Lstat(self.UnixPath); err == nil {
+		if info.Mode()&fs.ModeSocket == 0 {
+			return fmt.Errorf("not a socket: %s", self.UnixPath)
+		}
+		if err := os.Remove(self.UnixPath); (err != nil) && !os.IsNotExist(err) {
+			return err
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	if self.UnixDatagram {
+		var packetConn net.PacketConn
+		if err := self.listenUnix(func(path string) (io.Closer, error) {
+			var err error
+			packetConn, err = net.ListenPacket("unixgram", path)
+			return packetConn, err
+		}); err == nil {
+			self.Log.Notice("starting logger server",
+				"index", len(self.packetConns),
+				"level2protocol", "unixgram",
+				"path", self.UnixPath)
+
+			self.packetConns = append(self.packetConns, packetConn)
+			self.handlers.Add(1)
+			go self.receive(packetConn)
 			return nil
 		} else {
 			return err
 		}
 	} else {
+		var listener *net.UnixListener
+		if err := self.listenUnix(func(path string) (io.Closer, error) {
+			var err error
+			if listener, err = net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"}); err == nil {
+				// The socket will have been moved, so we remove it ourselves
+				listener.SetUnlinkOnClose(false)
+			}
+			return listener, err
+		}); err == nil {
+			self.Log.Notice("starting logger server",
+				"index", len(self.listeners),
+				"level2protocol", "unix",
+				"path", self.UnixPath)
+
+			self.listeners = append(self.listeners, listener)
+			go self.accept(listener)
+			return nil
+		} else {
+			return err
+		}
+	}
+}
+
+// Creates the socket in a new private directory, so that no one can
+// connect to it before it has UnixPermissions, and then moves it to
+// UnixPath.
+func (self *LoggerServer) listenUnix(listen func(path string) (io.Closer, error)) error {
+	dir, err := os.MkdirTemp(filepath.Dir(self.UnixPath), ".logger-server-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "socket")
+	closer, err := listen(path)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Chmod(path, self.UnixPermissions); err != nil {
+		closer.Close()
+		return err
+	}
+
+	if err := os.Rename(path, self.UnixPath); err != nil {
+		closer.Close()
 		return err
 	}
+
+	return nil
+}
+
+func (self *LoggerServer) accept(listener net.Listener) {
+	for {
+		if conn, err := listener.Accept(); err == nil {
+			if err := self.track(conn); err == nil {
+				self.Log.Debug("accepted logger server connection")
+				go self.handle(conn)
+			} else {
+				if err == errTooManyConnections {
+					self.Log.Warning("too many logger server connections",
+						"remoteAddress", conn.RemoteAddr().String())
+				}
+				CallAndLogError(conn.Close, "Conn.Close", self.Log)
+			}
+		} else {
+			if !errors.Is(err, net.ErrClosed) {
+				self.Log.Critical(err.Error())
+			}
+			return
+		}
+	}
+}
+
+// Each datagram is a single line.
+func (self *LoggerServer) receive(packetConn net.PacketConn) {
+	defer self.handlers.Done()
+
+	maxLineLength := self.MaxLineLength
+	if maxLineLength <= 0 {
+		maxLineLength = DefaultMaxLineLength
+	}
+
+	// We need room for the token line
+	var tokenLength int
+	if self.Token != "" {
+		tokenLength = len(self.Token) + 2
+	}
+
+	// One more byte in order to detect truncation
+	buffer := make([]byte, tokenLength+maxLineLength+1)
+
+	for {
+		count, address, err := packetConn.ReadFrom(buffer)
+
+		if count > 0 {
+			line := string(buffer[:count])
+
+			if self.Token != "" {
+				token, rest, _ := strings.Cut(line, "\n")
+				token = strings.TrimSuffix(token, "\r")
+				if subtle.ConstantTimeCompare([]byte(token), []byte(self.Token)) != 1 {
+					self.Log.Warning("logger server dropped datagram: wrong token")
+					continue
+				}
+				line = rest
+			}
+
+			line = strings.TrimRight(line, "\r\n")
+
+			truncated := false
+			if len(line) > maxLineLength {
+				line = line[:maxLineLength]
+				truncated = true
+			}
+
+			if line != "" {
+				log := self.Log
+				if address := remoteAddress(address); address != "" {
+					log = NewKeyValueLogger(log, "remoteAddress", address)
+				}
+
+				if message := self.newParse(log)(line); message != nil {
+					if truncated {
+						message.Set("truncated", true)
+					}
+					message.Send()
+				}
+			}
+		}
+
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				self.Log.Critical(err.Error())
+			}
+			return
+		}
+	}
 }
 
 func (self *LoggerServer) handle(conn net.Conn) {
+	defer self.handlers.Done()
+	defer self.untrack(conn)
 	defer CallAndLogError(func() error {
 		self.Log.Debug("closing logger server connection")
-		return conn.Close()
+		if err := conn.Close(); !errors.Is(err, net.ErrClosed) {
+			// Note: the connection might have been closed by Stop or Shutdown
+			return err
+		}
+		return nil
 	}, "Conn.Close", self.Log)
 
-	scanner := bufio.NewScanner(conn)
-	for scanner.Scan() {
-		self.Log.Log(self.Level, 0, scanner.Text())
+	var log Logger = self.Log
+	if address := remoteAddress(conn.RemoteAddr()); address != "" {
+		log = NewKeyValueLogger(log, "remoteAddress", address)
+	}
+
+	maxLineLength := self.MaxLineLength
+	if maxLineLength <= 0 {
+		maxLineLength = DefaultMaxLineLength
+	}
+	reader := bufio.NewReaderSize(conn, maxLineLength)
+
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		if !self.tlsHandshake(tlsConn, log) {
+			return
+		}
+	}
+
+	if self.Token != "" {
+		if !self.handshake(conn, reader, log) {
+			return
+		}
+	}
+
+	parse := self.newParse(log)
+
+	var interval time.Duration
+	var next time.Time
+	if self.MaxLinesPerSecond > 0 {
+		interval = time.Duration(float64(time.Second) / self.MaxLinesPerSecond)
+	}
+
+	for {
+		line, truncated, err := ReadLine(reader)
+
+		if line != "" {
+			if interval > 0 {
+				if now := time.Now(); now.Before(next) {
+					time.Sleep(next.Sub(now))
+					next = next.Add(interval)
+				} else {
+					next = now.Add(interval)
+				}
+			}
+
+			if message := parse(line); message != nil {
+				if truncated {
+					message.Set("truncated", true)
+				}
+				message.Send()
+			}
+		}
+
+		if err != nil {
+			if (err != io.EOF) && !errors.Is(err, net.ErrClosed) {
+				log.Error(err.Error())
+			}
+			return
+		}
+	}
+}
+
+// Note: otherwise the TLS handshake would happen lazily on the first read,
+// which does not have a deadline unless we have a token.
+func (self *LoggerServer) tlsHandshake(conn *tls.Conn, log Logger) bool {
+	context, cancel := contextpkg.WithTimeout(contextpkg.Background(), self.handshakeTimeout())
+	defer cancel()
+
+	if err := conn.HandshakeContext(context); err != nil {
+		log.Warning("logger server TLS handshake failed",
+			"error", err.Error())
+		return false
+	}
+
+	return true
+}
+
+func (self *LoggerServer) handshake(conn net.Conn, reader *bufio.Reader, log Logger) bool {
+	timeout := self.handshakeTimeout()
+
+	if err := conn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+		log.Error(err.Error())
+		return false
+	}
+
+	token, _, err := ReadLine(reader)
+	if err != nil {
+		log.Warning("logger server handshake failed",
+			"error", err.Error())
+		return false
+	}
+
+	if subtle.ConstantTimeCompare([]byte(token), []byte(self.Token)) != 1 {
+		log.Warning("logger server handshake failed: wrong token")
+		return false
+	}
+
+	if err := conn.SetReadDeadline(time.Time{}); err != nil {
+		log.Error(err.Error())
+		return false
+	}
+
+	return true
+}
+
+func (self *LoggerServer) newParse(log Logger) LineParseFunc {
+	parser := self.Parser
+	if parser == nil {
+		parser = PlainLineParser
+	}
+	return parser(log, self.Level)
+}
+
+func (self *LoggerServer) handshakeTimeout() time.Duration {
+	if self.HandshakeTimeout > 0 {
+		return self.HandshakeTimeout
+	}
+	return DefaultHandshakeTimeout
+}
+
+var (
+	errShuttingDown       = errors.New("shutting down")
+	errTooManyConnections = errors.New("too many connections")
+)
+
+func (self *LoggerServer) track(conn net.Conn) error {
+	self.connsLock.Lock()
+	defer self.connsLock.Unlock()
+
+	// Note: a connection might have been accepted right before its listener
+	// was closed, in which case we might have already called closeConns and
+	// possibly handlers.Wait
+	if self.shuttingDown {
+		return errShuttingDown
+	}
+
+	if (self.MaxConnections > 0) && (len(self.conns) >= self.MaxConnections) {
+		return errTooManyConnections
+	}
+
+	if self.conns == nil {
+		self.conns = make(map[net.Conn]struct{})
+	}
+	self.conns[conn] = struct{}{}
+	self.handlers.Add(1)
+
+	return nil
+}
+
+func (self *LoggerServer) untrack(conn net.Conn) {
+	self.connsLock.Lock()
+	defer self.connsLock.Unlock()
+
+	delete(self.conns, conn)
+}
+
+// Note: datagram sockets are closed here, too, as there are no
+// connections to drain.
+func (self *LoggerServer) closeListeners() {
+	self.connsLock.Lock()
+	self.shuttingDown = true
+	self.connsLock.Unlock()
+
+	for index, listener := range self.listeners {
+		self.Log.Notice("stopping logger server",
+			"index", index)
+		if err := listener.Close(); err != nil {
+			self.Log.Error(err.Error())
+		}
+		if listener.Addr().Network() == "unix" {
+			self.removeUnixPath()
+		}
+		self.Log.Notice("stopped logger server",
+			"index", index)
+	}
+	self.listeners = nil
+
+	for index, packetConn := range self.packetConns {
+		self.Log.Notice("stopping logger server",
+			"index", index,
+			"datagram", true)
+		if err := packetConn.Close(); err != nil {
+			self.Log.Error(err.Error())
+		}
+		if packetConn.LocalAddr().Network() == "unixgram" {
+			self.removeUnixPath()
+		}
+		self.Log.Notice("stopped logger server",
+			"index", index,
+			"datagram", true)
 	}
+	self.packetConns = nil
+}
 
-	if err := scanner.Err(); err != nil {
+func (self *LoggerServer) removeUnixPath() {
+	if err := os.Remove(self.UnixPath); (err != nil) && !os.IsNotExist(err) {
 		self.Log.Error(err.Error())
 	}
 }
+
+func (self *LoggerServer) closeConns() {
+	self.connsLock.Lock()
+	defer self.connsLock.Unlock()
+
+	for conn := range self.conns {
+		conn.Close()
+	}
+}
+
+// Unix socket clients are usually unnamed.
+func remoteAddress(address net.Addr) string {
+	if address != nil {
+		if address_ := address.String(); address_ != "@" {
+			return address_
+		}
+	}
+	return ""
+}
+
+// Reads a line up to the reader's buffer size, without the trailing "\n"
+// or "\r\n". If the line is longer it is truncated, the rest of it is
+// discarded, and truncated is returned as true.
+//
+// A non-empty line may be returned together with an error, e.g. for the
+// last line before [io.EOF].
+func ReadLine(reader *bufio.Reader) (string, bool, error) {
+	bytes, err := reader.ReadSlice('\n')
+
+	truncated := false
+	if err == bufio.ErrBufferFull {
+		truncated = true
+		bytes = append(bytes[:0:0], bytes...)
+		for err == bufio.ErrBufferFull {
+			_, err = reader.ReadSlice('\n')
+		}
+	}
+
+	line := string(bytes)
+	if length := len(line); (length > 0) && (line[length-1] == '\n') {
+		line = line[:length-1]
+		if length := len(line); (length > 0) && (line[length-1] == '\r') {
+			line = line[:length-1]
+		}
+	}
+
+	return line, truncated, err
+}
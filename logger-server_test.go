@@ -0,0 +1,77 @@
+package commonlog
+
+import (
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoggerServerUnix(t *testing.T) {
+	for _, datagram := range []bool{false, true} {
+		network := "unix"
+		if datagram {
+			network = "unixgram"
+		}
+
+		t.Run(network, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "socket")
+
+			server := NewUnixLoggerServer(path, datagram, MOCK_LOGGER, Info)
+			server.UnixPermissions = 0640
+			if err := server.Start(); err != nil {
+				t.Fatal(err)
+			}
+
+			info, err := os.Lstat(path)
+			if err != nil {
+				server.Stop()
+				t.Fatal(err)
+			}
+			if info.Mode()&fs.ModeSocket == 0 {
+				t.Errorf("mode = %s, expected a socket", info.Mode())
+			}
+			if permissions := info.Mode().Perm(); permissions != 0640 {
+				t.Errorf("permissions = %o, expected 640", permissions)
+			}
+
+			if conn, err := net.Dial(network, path); err == nil {
+				conn.Close()
+			} else {
+				t.Error(err)
+			}
+
+			// The private directory was removed
+			if entries, err := os.ReadDir(dir); err != nil {
+				t.Error(err)
+			} else if len(entries) != 1 {
+				t.Errorf("got %d entries, expected 1", len(entries))
+			}
+
+			server.Stop()
+
+			if _, err := os.Lstat(path); !os.IsNotExist(err) {
+				t.Errorf("socket was not removed: %v", err)
+			}
+		})
+	}
+}
+
+func TestLoggerServerUnixNotSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(path, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	server := NewUnixLoggerServer(path, false, MOCK_LOGGER, Info)
+	if err := server.Start(); err == nil {
+		server.Stop()
+		t.Fatal("Start() succeeded")
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("file was removed: %s", err)
+	}
+}
@@ -0,0 +1,122 @@
+package commonlog
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+//
+// LoggerWriter
+//
+
+// An [io.WriteCloser] that splits everything written to it into lines and
+// sends each line as a message to a [Logger]. Writes do not have to be
+// aligned to lines. Both "\n" and "\r\n" line endings are supported.
+//
+// Lines longer than MaxLineLength are truncated and will have a
+// "truncated" key. A final line without a line ending is sent on
+// [LoggerWriter.Close].
+//
+// Lines are parsed by Parse, which defaults to [PlainLineParser] at the
+// writer's level.
+type LoggerWriter struct {
+	Log           Logger
+	Level         Level
+	Parse         LineParseFunc
+	MaxLineLength int
+
+	buffer    []byte
+	truncated bool
+	closed    bool
+	lock      sync.Mutex
+}
+
+func NewLoggerWriter(log Logger, level Level) *LoggerWriter {
+	return &LoggerWriter{
+		Log:           log,
+		Level:         level,
+		Parse:         PlainLineParser(log, level),
+		MaxLineLength: DefaultMaxLineLength,
+	}
+}
+
+// ([io.Writer] interface)
+func (self *LoggerWriter) Write(p []byte) (int, error) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	if self.closed {
+		return 0, io.ErrClosedPipe
+	}
+
+	length := len(p)
+	for len(p) > 0 {
+		if index := bytes.IndexByte(p, '\n'); index != -1 {
+			self.append(p[:index])
+			self.flush()
+			p = p[index+1:]
+		} else {
+			self.append(p)
+			break
+		}
+	}
+
+	return length, nil
+}
+
+// ([io.Closer] interface)
+func (self *LoggerWriter) Close() error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	if self.closed {
+		return nil
+	}
+
+	if (len(self.buffer) > 0) || self.truncated {
+		self.flush()
+	}
+
+	self.closed = true
+	self.buffer = nil
+	return nil
+}
+
+// Call with lock.
+func (self *LoggerWriter) append(p []byte) {
+	if self.MaxLineLength > 0 {
+		if available := self.MaxLineLength - len(self.buffer); len(p) > available {
+			if available < 0 {
+				available = 0
+			}
+			p = p[:available]
+			self.truncated = true
+		}
+	}
+
+	self.buffer = append(self.buffer, p...)
+}
+
+// Call with lock.
+func (self *LoggerWriter) flush() {
+	line := self.buffer
+	if length := len(line); (length > 0) && (line[length-1] == '\r') {
+		line = line[:length-1]
+	}
+
+	parse := self.Parse
+	if parse == nil {
+		parse = PlainLineParser(self.Log, self.Level)
+	}
+
+	if message := parse(string(line)); message != nil {
+		if self.truncated {
+			message.Set("truncated", true)
+		}
+		message.Send()
+	}
+
+	self.buffer = self.buffer[:0]
+	self.truncated = false
+}
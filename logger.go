@@ -1,5 +1,9 @@
 package commonlog
 
+import (
+	"io"
+)
+
 //
 // Logger
 //
@@ -37,6 +41,13 @@ type Logger interface {
 	// and args similarly to fmt.Printf.
 	Logf(level Level, depth int, format string, args ...any)
 
+	// Creates a writer that sends each line written to it as a message
+	// at the level (see [LoggerWriter]). Unlike [GetWriter], the messages
+	// are filtered and formatted by the backend like any other message.
+	// Make sure to close the writer in order to flush a final partial
+	// line.
+	Writer(level Level) io.WriteCloser
+
 	Critical(message string, keysAndValues ...any)
 	Criticalf(format string, args ...any)
 	Error(message string, keysAndValues ...any)
@@ -0,0 +1,172 @@
+package logr
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/go-logr/logr"
+	"github.com/go-logr/logr/funcr"
+	"github.com/tliron/commonlog"
+	"github.com/tliron/go-kutil/util"
+)
+
+const (
+	LogFileWritePermissions = 0600
+	DefaultBufferSize       = 1_000
+	ErrorKey                = "error"
+)
+
+func init() {
+	backend := NewBackend()
+	backend.Configure(0, nil)
+	commonlog.SetBackend(backend)
+}
+
+//
+// Backend
+//
+
+// Sends messages to a [logr.Logger].
+//
+// If Logger is set before calling Configure then it will be used as is,
+// otherwise Configure will create a [funcr] logger that writes to stderr
+// or to the log file. The maximum level is handled by us, so the logger we
+// create has a verbosity of 2. A logger that you set will still filter
+// messages according to its own verbosity, so it should likewise be
+// configured for V(2) (e.g. with [funcr.Options] Verbosity) in order to
+// allow Info and Debug messages.
+//
+// Names are mapped to [logr.Logger.WithName] and levels are mapped as
+// follows:
+//
+//   - [commonlog.Critical] and [commonlog.Error]: [logr.Logger.Error]
+//   - [commonlog.Warning] and [commonlog.Notice]: V(0)
+//   - [commonlog.Info]: V(1)
+//   - [commonlog.Debug]: V(2)
+//
+// An "error" key with an error value on error messages becomes the error
+// argument. Note that [funcr] ignores the logger's verbosity for errors.
+type Backend struct {
+	Logger     logr.Logger
+	Writer     io.Writer
+	BufferSize int
+	Buffered   bool
+
+	ownLogger     bool
+	nameHierarchy *commonlog.NameHierarchy
+}
+
+func NewBackend() *Backend {
+	return &Backend{
+		BufferSize:    DefaultBufferSize,
+		Buffered:      true,
+		nameHierarchy: commonlog.NewNameHierarchy(),
+	}
+}
+
+// ([commonlog.Backend] interface)
+func (self *Backend) Configure(verbosity int, path *string) {
+	maxLevel := commonlog.VerbosityToMaxLevel(verbosity)
+	createLogger := (self.Logger.GetSink() == nil) || self.ownLogger
+
+	if maxLevel == commonlog.None {
+		self.Writer = io.Discard
+		if createLogger {
+			self.Logger = logr.Discard()
+			self.ownLogger = true
+		}
+		self.nameHierarchy.SetMaxLevel(commonlog.None)
+	} else {
+		if createLogger {
+			if path != nil {
+				if file, err := os.OpenFile(*path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, LogFileWritePermissions); err == nil {
+					util.OnExitError(file.Close)
+					if self.Buffered {
+						writer := util.NewBufferedWriter(file, self.BufferSize, false)
+						util.OnExitError(writer.Close)
+						self.Writer = writer
+					} else {
+						self.Writer = util.NewSyncedWriter(file)
+					}
+				} else {
+					util.Failf("log file error: %s", err.Error())
+				}
+			} else if self.Buffered {
+				writer := util.NewBufferedWriter(commonlog.Stderr, self.BufferSize, false)
+				util.OnExitError(writer.Close)
+				self.Writer = writer
+			} else {
+				self.Writer = util.NewSyncedWriter(commonlog.Stderr)
+			}
+
+			writer := self.Writer
+			self.Logger = funcr.New(func(prefix string, args string) {
+				if prefix != "" {
+					fmt.Fprintf(writer, "%s: %s\n", prefix, args)
+				} else {
+					fmt.Fprintln(writer, args)
+				}
+			}, funcr.Options{
+				LogTimestamp: true,
+				Verbosity:    2,
+			})
+			self.ownLogger = true
+		}
+
+		self.nameHierarchy.SetMaxLevel(maxLevel)
+	}
+}
+
+// ([commonlog.Backend] interface)
+func (self *Backend) GetWriter() io.Writer {
+	return self.Writer
+}
+
+// ([commonlog.Backend] interface)
+func (self *Backend) NewMessage(level commonlog.Level, depth int, name ...string) commonlog.Message {
+	if self.AllowLevel(level, name...) {
+		var isError bool
+		var verbosity int
+		switch level {
+		case commonlog.Critical, commonlog.Error:
+			isError = true
+		case commonlog.Warning, commonlog.Notice:
+			verbosity = 0
+		case commonlog.Info:
+			verbosity = 1
+		case commonlog.Debug:
+			verbosity = 2
+		default:
+			panic(fmt.Sprintf("unsupported log level: %d", level))
+		}
+
+		logger := self.Logger
+		for _, segment := range name {
+			logger = logger.WithName(segment)
+		}
+
+		// Note: we are not using logr.Logger.WithCallDepth, because the
+		// message will be sent from a different call site. Instead, the
+		// location is set by TraceMessage if commonlog.Trace is true.
+
+		return commonlog.TraceMessage(NewMessage(logger, isError, verbosity), depth)
+	} else {
+		return nil
+	}
+}
+
+// ([commonlog.Backend] interface)
+func (self *Backend) AllowLevel(level commonlog.Level, name ...string) bool {
+	return self.nameHierarchy.AllowLevel(level, name...)
+}
+
+// ([commonlog.Backend] interface)
+func (self *Backend) SetMaxLevel(level commonlog.Level, name ...string) {
+	self.nameHierarchy.SetMaxLevel(level, name...)
+}
+
+// ([commonlog.Backend] interface)
+func (self *Backend) GetMaxLevel(name ...string) commonlog.Level {
+	return self.nameHierarchy.GetMaxLevel(name...)
+}
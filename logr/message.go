@@ -0,0 +1,61 @@
+package logr
+
+import (
+	"github.com/go-logr/logr"
+	"github.com/tliron/commonlog"
+	"github.com/tliron/go-kutil/util"
+)
+
+//
+// Message
+//
+
+type Message struct {
+	logger    logr.Logger
+	isError   bool
+	verbosity int
+
+	message       string
+	err           error
+	keysAndValues []any
+}
+
+// If isError is true then the message will be sent via [logr.Logger.Error],
+// otherwise via [logr.Logger.Info] at the verbosity.
+func NewMessage(logger logr.Logger, isError bool, verbosity int) commonlog.Message {
+	return &Message{
+		logger:    logger,
+		isError:   isError,
+		verbosity: verbosity,
+	}
+}
+
+// ([commonlog.Message] interface)
+func (self *Message) Set(key string, value any) commonlog.Message {
+	switch key {
+	case commonlog.MESSAGE:
+		self.message = util.ToString(value)
+
+	case ErrorKey:
+		// Will be the argument to logr.Logger.Error
+		if err, ok := value.(error); ok && self.isError && (self.err == nil) {
+			self.err = err
+			break
+		}
+		self.keysAndValues = append(self.keysAndValues, key, value)
+
+	default:
+		self.keysAndValues = append(self.keysAndValues, key, value)
+	}
+
+	return self
+}
+
+// ([commonlog.Message] interface)
+func (self *Message) Send() {
+	if self.isError {
+		self.logger.Error(self.err, self.message, self.keysAndValues...)
+	} else {
+		self.logger.V(self.verbosity).Info(self.message, self.keysAndValues...)
+	}
+}
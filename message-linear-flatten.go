@@ -0,0 +1,233 @@
+package commonlog
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/tliron/go-kutil/util"
+)
+
+const (
+	DefaultFlattenMaxDepth = 4
+	DefaultFlattenMaxSize  = 64
+
+	// Struct tag used by [FlattenValue]. Format is "name,omitempty,redact",
+	// where all parts are optional. A name of "-" excludes the field.
+	FLATTEN_TAG = "log"
+
+	REDACTED = "[redacted]"
+
+	// Appended to the key prefix by [FlattenValue] when the size limit
+	// was reached.
+	TRUNCATED = "_truncated"
+)
+
+// Flattens nested maps, structs, slices, and arrays into values with
+// dotted keys, e.g. "user.id" and "user.name". Struct fields can be
+// renamed or excluded via the "log" tag (see [FLATTEN_TAG]).
+//
+// Values that implement [fmt.Stringer] or [error] are not flattened.
+// Beyond maxDepth nested values are not flattened. If the result would
+// contain more than maxSize values it is truncated and a final value with
+// the "_truncated" key (under the key prefix) is added.
+//
+// A maxDepth of 0 disables flattening.
+func FlattenValue(key string, value any, maxDepth int, maxSize int) []LinearMessageValue {
+	if maxDepth <= 0 {
+		return []LinearMessageValue{NewLinearMessageValue(key, value)}
+	}
+
+	flattener := flattener{
+		root:     key,
+		maxDepth: maxDepth,
+		maxSize:  maxSize,
+	}
+	flattener.flatten(key, value, 0)
+	return flattener.values
+}
+
+//
+// flattener
+//
+
+type flattener struct {
+	root      string
+	maxDepth  int
+	maxSize   int
+	values    []LinearMessageValue
+	truncated bool
+}
+
+func (self *flattener) add(key string, value any) bool {
+	if self.truncated {
+		return false
+	}
+
+	if (self.maxSize > 0) && (len(self.values) >= self.maxSize) {
+		self.truncated = true
+		self.values = append(self.values, NewLinearMessageValue(self.root+"."+TRUNCATED, true))
+		return false
+	}
+
+	self.values = append(self.values, NewLinearMessageValue(key, value))
+	return true
+}
+
+func (self *flattener) flatten(key string, value any, depth int) bool {
+	if value == nil {
+		return self.add(key, value)
+	}
+
+	switch value.(type) {
+	case fmt.Stringer, error, []byte:
+		return self.add(key, value)
+	}
+
+	value_ := reflect.ValueOf(value)
+	for value_.Kind() == reflect.Pointer {
+		if value_.IsNil() {
+			return self.add(key, nil)
+		}
+		value_ = value_.Elem()
+	}
+
+	if depth >= self.maxDepth {
+		return self.add(key, value_.Interface())
+	}
+
+	switch value_.Kind() {
+	case reflect.Map:
+		if value_.Len() == 0 {
+			return self.add(key, value_.Interface())
+		}
+
+		keys := value_.MapKeys()
+		mapKeys := make([]string, len(keys))
+		for index, key_ := range keys {
+			mapKeys[index] = util.ToString(key_.Interface())
+		}
+		indexes := make([]int, len(keys))
+		for index := range indexes {
+			indexes[index] = index
+		}
+		slices.SortFunc(indexes, func(a int, b int) int {
+			return strings.Compare(mapKeys[a], mapKeys[b])
+		})
+
+		for _, index := range indexes {
+			if !self.flatten(key+"."+mapKeys[index], value_.MapIndex(keys[index]).Interface(), depth+1) {
+				return false
+			}
+		}
+		return true
+
+	case reflect.Slice, reflect.Array:
+		if value_.Len() == 0 {
+			return self.add(key, value_.Interface())
+		}
+
+		for index := range value_.Len() {
+			if !self.flatten(key+"."+strconv.Itoa(index), value_.Index(index).Interface(), depth+1) {
+				return false
+			}
+		}
+		return true
+
+	case reflect.Struct:
+		if !value_.CanInterface() {
+			return self.add(key, value)
+		}
+
+		added := false
+		if !self.flattenStruct(key, value_, depth, &added) {
+			return false
+		}
+		if !added {
+			return self.add(key, value_.Interface())
+		}
+		return true
+
+	default:
+		return self.add(key, value_.Interface())
+	}
+}
+
+func (self *flattener) flattenStruct(key string, value reflect.Value, depth int, added *bool) bool {
+	type_ := value.Type()
+	for index := range type_.NumField() {
+		field := type_.Field(index)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, omitEmpty, redact, ok := parseFlattenTag(field)
+		if !ok {
+			continue
+		}
+
+		fieldValue := value.Field(index)
+		if omitEmpty && fieldValue.IsZero() {
+			continue
+		}
+
+		if field.Anonymous && (name == "") {
+			// Inline embedded structs
+			embedded := fieldValue
+			for embedded.Kind() == reflect.Pointer {
+				if embedded.IsNil() {
+					break
+				}
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				if !self.flattenStruct(key, embedded, depth, added) {
+					return false
+				}
+				continue
+			}
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		*added = true
+		if redact {
+			if !self.add(key+"."+name, REDACTED) {
+				return false
+			}
+		} else if !self.flatten(key+"."+name, fieldValue.Interface(), depth+1) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Returns false if the field should be excluded.
+func parseFlattenTag(field reflect.StructField) (string, bool, bool, bool) {
+	tag, ok := field.Tag.Lookup(FLATTEN_TAG)
+	if !ok {
+		return "", false, false, true
+	}
+
+	if tag == "-" {
+		return "", false, false, false
+	}
+
+	name, options, _ := strings.Cut(tag, ",")
+	var omitEmpty, redact bool
+	for _, option := range strings.Split(options, ",") {
+		switch option {
+		case "omitempty":
+			omitEmpty = true
+		case "redact":
+			redact = true
+		}
+	}
+
+	return name, omitEmpty, redact, true
+}
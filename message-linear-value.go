@@ -0,0 +1,134 @@
+package commonlog
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tliron/go-kutil/util"
+)
+
+//
+// LinearMessageValue
+//
+
+// A key-value pair for [LinearMessage]. The original value is retained
+// so that formatters can render it according to its type, while its
+// string representation is computed only when needed.
+type LinearMessageValue struct {
+	Key   string
+	Value any
+
+	string_     string
+	stringified bool
+}
+
+func NewLinearMessageValue(key string, value any) LinearMessageValue {
+	return LinearMessageValue{
+		Key:   key,
+		Value: value,
+	}
+}
+
+// Converts the value to a string. The result is cached.
+//
+// Errors will include messages from their wrapped errors if those are
+// not already included.
+//
+// ([fmt.Stringify] interface)
+func (self *LinearMessageValue) String() string {
+	if !self.stringified {
+		switch value := self.Value.(type) {
+		case time.Duration:
+			self.string_ = value.String()
+		case error:
+			self.string_ = ErrorChainString(value)
+		default:
+			self.string_ = util.ToString(value)
+		}
+		self.stringified = true
+	}
+	return self.string_
+}
+
+// Returns true if the value is a number, a boolean, or a [time.Duration].
+// These can be represented in text without quotes.
+func (self *LinearMessageValue) IsLiteral() bool {
+	switch self.Value.(type) {
+	case bool, time.Duration:
+		return true
+	default:
+		return util.IsNumber(self.Value)
+	}
+}
+
+// Representation suitable for "key=value" text. Literals (see
+// [LinearMessageValue.IsLiteral]) are unquoted while all other values are
+// quoted.
+func (self *LinearMessageValue) Literal() string {
+	if self.IsLiteral() {
+		return self.String()
+	} else {
+		return strconv.Quote(self.String())
+	}
+}
+
+// Representation as JSON. Values are emitted as native JSON types when
+// possible. Durations, errors, and [fmt.Stringer] implementations (unless
+// they implement [json.Marshaler]) are emitted as JSON strings.
+func (self *LinearMessageValue) JSON() []byte {
+	switch self.Value.(type) {
+	case nil:
+		return []byte("null")
+
+	case time.Duration, error:
+		return jsonString(self.String())
+
+	case json.Marshaler:
+
+	case fmt.Stringer:
+		return jsonString(self.String())
+	}
+
+	// Note: will fail for unsupported values, such as NaN floats and channels
+	if bytes, err := json.Marshal(self.Value); err == nil {
+		return bytes
+	} else {
+		return jsonString(self.String())
+	}
+}
+
+// Converts an error to a string that includes the messages of the errors
+// it wraps, unless they are already included in the wrapping message
+// (as is the case for [fmt.Errorf] with "%w").
+func ErrorChainString(err error) string {
+	if err == nil {
+		return "nil"
+	}
+
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		errs := joined.Unwrap()
+		strings_ := make([]string, len(errs))
+		for index, err_ := range errs {
+			strings_[index] = ErrorChainString(err_)
+		}
+		return strings.Join(strings_, "; ")
+	}
+
+	string_ := err.Error()
+	for err = errors.Unwrap(err); err != nil; err = errors.Unwrap(err) {
+		if cause := err.Error(); !strings.Contains(string_, cause) {
+			string_ += ": " + cause
+		}
+	}
+
+	return string_
+}
+
+func jsonString(s string) []byte {
+	bytes, _ := json.Marshal(s)
+	return bytes
+}
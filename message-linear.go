@@ -3,6 +3,7 @@ package commonlog
 import (
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/tliron/go-kutil/util"
 )
@@ -21,19 +22,25 @@ type LinearMessage struct {
 	Values  []LinearMessageValue
 	File    string
 	Line    int64
+	Time    time.Time
 
-	send SendLinearMessageFunc
-}
+	// See [FlattenValue].
+	FlattenMaxDepth int
+	FlattenMaxSize  int
+
+	// How newlines in the message text are rendered. Values are always
+	// quoted, so their newlines are escaped regardless of this setting.
+	Multiline MultilineMode
 
-type LinearMessageValue struct {
-	Key   string
-	Value string
+	send SendLinearMessageFunc
 }
 
 func NewLinearMessage(send SendLinearMessageFunc) *LinearMessage {
 	return &LinearMessage{
-		send: send,
-		Line: -1,
+		FlattenMaxDepth: DefaultFlattenMaxDepth,
+		FlattenMaxSize:  DefaultFlattenMaxSize,
+		send:            send,
+		Line:            -1,
 	}
 }
 
@@ -52,8 +59,13 @@ func (self *LinearMessage) Set(key string, value any) Message {
 	case LINE:
 		self.Line, _ = util.ToInt64(value)
 
+	case TIME:
+		if time_, ok := ToTime(value); ok {
+			self.Time = time_
+		}
+
 	default:
-		self.Values = append(self.Values, LinearMessageValue{key, util.ToString(value)})
+		self.Values = append(self.Values, NewLinearMessageValue(key, value))
 	}
 
 	return self
@@ -69,7 +81,7 @@ func (self *LinearMessage) String() string {
 	var builder strings.Builder
 
 	if len(self.Message) > 0 {
-		builder.WriteString(self.Message)
+		builder.WriteString(self.Multiline.Render(self.Message))
 	}
 
 	if values := self.ValuesString(true); values != "" {
@@ -79,7 +91,7 @@ func (self *LinearMessage) String() string {
 		builder.WriteString(values)
 	}
 
-	return strings.ReplaceAll(builder.String(), "\n", "¶")
+	return builder.String()
 }
 
 func (self *LinearMessage) Prefix(name ...string) string {
@@ -122,6 +134,19 @@ func (self *LinearMessage) StringWithPrefix(name ...string) string {
 	}
 }
 
+// Returns the values with nested values flattened (see [FlattenValue]).
+func (self *LinearMessage) FlatValues() []LinearMessageValue {
+	if self.FlattenMaxDepth <= 0 {
+		return self.Values
+	}
+
+	var values []LinearMessageValue
+	for _, value := range self.Values {
+		values = append(values, FlattenValue(value.Key, value.Value, self.FlattenMaxDepth, self.FlattenMaxSize)...)
+	}
+	return values
+}
+
 func (self *LinearMessage) ValuesString(withLocation bool) string {
 	if len(self.Values) == 0 {
 		return ""
@@ -131,21 +156,23 @@ func (self *LinearMessage) ValuesString(withLocation bool) string {
 
 	values.WriteRune('{')
 
-	values_ := self.Values
+	values_ := self.FlatValues()
 	if withLocation {
 		if self.File != "" {
-			values_ = append(values_, LinearMessageValue{FILE, self.File})
+			// Note: we must not append into our own backing array
+			values_ = append(values_[:len(values_):len(values_)], NewLinearMessageValue(FILE, self.File))
 			if self.Line != -1 {
-				values_ = append(values_, LinearMessageValue{LINE, strconv.FormatInt(self.Line, 10)})
+				values_ = append(values_, NewLinearMessageValue(LINE, self.Line))
 			}
 		}
 	}
 
 	last := len(values_) - 1
-	for index, value := range values_ {
+	for index := range values_ {
+		value := &values_[index]
 		values.WriteString(value.Key)
 		values.WriteRune('=')
-		values.WriteString(strconv.Quote(value.Value))
+		values.WriteString(value.Literal())
 		if index != last {
 			values.WriteRune(' ')
 		}
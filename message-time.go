@@ -0,0 +1,28 @@
+package commonlog
+
+import (
+	"time"
+)
+
+// Converts a "_time" value to a [time.Time].
+//
+// Supports [time.Time], *[time.Time], and strings in [time.RFC3339Nano]
+// format. Returns false if the value is not supported.
+func ToTime(value any) (time.Time, bool) {
+	switch value_ := value.(type) {
+	case time.Time:
+		return value_, true
+
+	case *time.Time:
+		if value_ != nil {
+			return *value_, true
+		}
+
+	case string:
+		if time_, err := time.Parse(time.RFC3339Nano, value_); err == nil {
+			return time_, true
+		}
+	}
+
+	return time.Time{}, false
+}
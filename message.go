@@ -5,6 +5,7 @@ const (
 	SCOPE   = "_scope"
 	FILE    = "_file"
 	LINE    = "_line"
+	TIME    = "_time"
 )
 
 //
@@ -24,6 +25,7 @@ type Message interface {
 	// "_scope": the scope of the message
 	// "_file": filename in which the message was created
 	// "_line": line number in the "_file"
+	// "_time": when the message was created (see [ToTime])
 	Set(key string, value any) Message
 
 	// Sends the message to the backend.
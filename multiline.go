@@ -0,0 +1,72 @@
+package commonlog
+
+import (
+	"strings"
+)
+
+//
+// MultilineMode
+//
+
+// Determines how newlines in message text are rendered by text-based
+// formatters.
+type MultilineMode int
+
+const (
+	// Replaces newlines with "¶", keeping the message on a single line.
+	MultilinePilcrow MultilineMode = 0
+
+	// Replaces newlines with the "\n" escape sequence.
+	MultilineEscape MultilineMode = 1
+
+	// Keeps newlines, prefixing each continuation line with
+	// [MultilineIndentPrefix].
+	MultilineIndent MultilineMode = 2
+
+	// Keeps newlines as is.
+	MultilineRaw MultilineMode = 3
+)
+
+const MultilineIndentPrefix = "│ "
+
+// ([fmt.Stringify] interface)
+func (self MultilineMode) String() string {
+	switch self {
+	case MultilinePilcrow:
+		return "pilcrow"
+	case MultilineEscape:
+		return "escape"
+	case MultilineIndent:
+		return "indent"
+	case MultilineRaw:
+		return "raw"
+	default:
+		return ""
+	}
+}
+
+// Renders the newlines in the text according to the mode. Both "\n" and
+// "\r\n" are treated as newlines.
+func (self MultilineMode) Render(text string) string {
+	if !strings.Contains(text, "\n") {
+		return text
+	}
+
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+
+	switch self {
+	case MultilineEscape:
+		return strings.ReplaceAll(text, "\n", `\n`)
+
+	case MultilineIndent:
+		// A trailing newline would otherwise result in an empty continuation line
+		text = strings.TrimRight(text, "\n")
+		return strings.ReplaceAll(text, "\n", "\n"+MultilineIndentPrefix)
+
+	case MultilineRaw:
+		return text
+
+	default:
+		return strings.ReplaceAll(text, "\n", "¶")
+	}
+}
@@ -46,6 +46,11 @@ func (self *NameHierarchy) SetMaxLevel(level Level, name ...string) {
 	node.maxLevel = level
 }
 
+// Gets the highest maximum level set for the root or for any name.
+func (self *NameHierarchy) GetHighestMaxLevel() Level {
+	return self.root.getHighestMaxLevel()
+}
+
 //
 // nameHierarchyNode
 //
@@ -61,3 +66,13 @@ func newMaxLevelHierarchyNode() *nameHierarchyNode {
 		children: make(map[string]*nameHierarchyNode),
 	}
 }
+
+func (self *nameHierarchyNode) getHighestMaxLevel() Level {
+	level := self.maxLevel
+	for _, child := range self.children {
+		if level_ := child.getHighestMaxLevel(); level_ > level {
+			level = level_
+		}
+	}
+	return level
+}
@@ -0,0 +1,173 @@
+package remote
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/url"
+	"time"
+
+	"github.com/tliron/commonlog"
+	"github.com/tliron/go-kutil/util"
+)
+
+const (
+	DefaultBufferSize  = 1_000
+	DefaultMinBackoff  = 100 * time.Millisecond
+	DefaultMaxBackoff  = 30 * time.Second
+	DefaultDialTimeout = 10 * time.Second
+	DefaultMaxFlush    = 5 * time.Second
+)
+
+func init() {
+	backend := NewBackend()
+	backend.Configure(0, nil)
+	commonlog.SetBackend(backend)
+}
+
+//
+// Backend
+//
+
+// Sends messages as JSON lines to a remote [commonlog.LoggerServer], which
+// should be using [commonlog.JSONLineParser].
+//
+// A single connection is reused for all messages. While disconnected,
+// messages are buffered in memory (up to BufferSize messages) and the
+// connection is retried with exponential backoff (between MinBackoff and
+// MaxBackoff). Messages that do not fit in the buffer are written to the
+// Fallback writer.
+//
+// When Token is set it is sent as the first line on each connection (see
+// [commonlog.LoggerServer] Token).
+type Backend struct {
+	Token       string
+	TLSConfig   *tls.Config
+	BufferSize  int
+	MinBackoff  time.Duration
+	MaxBackoff  time.Duration
+	DialTimeout time.Duration
+
+	// Defaults to stderr.
+	Fallback io.Writer
+
+	// Maximum time to wait for buffered messages to be sent on exit.
+	MaxFlush time.Duration
+
+	sender        *sender
+	exitHandle    util.ExitFunctionHandle
+	writer        io.Writer
+	nameHierarchy *commonlog.NameHierarchy
+}
+
+func NewBackend() *Backend {
+	return &Backend{
+		BufferSize:    DefaultBufferSize,
+		MinBackoff:    DefaultMinBackoff,
+		MaxBackoff:    DefaultMaxBackoff,
+		DialTimeout:   DefaultDialTimeout,
+		MaxFlush:      DefaultMaxFlush,
+		Fallback:      util.NewSyncedWriter(commonlog.Stderr),
+		nameHierarchy: commonlog.NewNameHierarchy(),
+	}
+}
+
+// The path is a URL in the form "tcp://host:port", "tls://host:port", or
+// "unix:///path". If nil all messages are written to the Fallback writer.
+//
+// ([commonlog.Backend] interface)
+func (self *Backend) Configure(verbosity int, path *string) {
+	maxLevel := commonlog.VerbosityToMaxLevel(verbosity)
+
+	if self.sender != nil {
+		self.sender.close(self.MaxFlush)
+		self.sender = nil
+	}
+
+	if maxLevel == commonlog.None {
+		self.writer = io.Discard
+		self.nameHierarchy.SetMaxLevel(commonlog.None)
+	} else {
+		if path != nil {
+			if network, address, err := parseURL(*path); err == nil {
+				self.sender = newSender(self, network, address)
+				if self.exitHandle == 0 {
+					self.exitHandle = util.OnExit(func() {
+						if sender := self.sender; sender != nil {
+							sender.close(self.MaxFlush)
+						}
+					})
+				}
+			} else {
+				util.Failf("remote log error: %s", err.Error())
+			}
+		}
+
+		self.writer = RemoteWriter{self}
+		self.nameHierarchy.SetMaxLevel(maxLevel)
+	}
+}
+
+// ([commonlog.Backend] interface)
+func (self *Backend) GetWriter() io.Writer {
+	return self.writer
+}
+
+// ([commonlog.Backend] interface)
+func (self *Backend) NewMessage(level commonlog.Level, depth int, name ...string) commonlog.Message {
+	if self.AllowLevel(level, name...) {
+		message := commonlog.NewLinearMessage(func(message *commonlog.LinearMessage) {
+			self.send(FormatJSON(message, name, level))
+		})
+
+		message.Time = time.Now()
+
+		return commonlog.TraceMessage(message, depth)
+	} else {
+		return nil
+	}
+}
+
+// ([commonlog.Backend] interface)
+func (self *Backend) AllowLevel(level commonlog.Level, name ...string) bool {
+	return self.nameHierarchy.AllowLevel(level, name...)
+}
+
+// ([commonlog.Backend] interface)
+func (self *Backend) SetMaxLevel(level commonlog.Level, name ...string) {
+	self.nameHierarchy.SetMaxLevel(level, name...)
+}
+
+// ([commonlog.Backend] interface)
+func (self *Backend) GetMaxLevel(name ...string) commonlog.Level {
+	return self.nameHierarchy.GetMaxLevel(name...)
+}
+
+func (self *Backend) send(line []byte) {
+	if (self.sender == nil) || !self.sender.enqueue(line) {
+		self.fallback(line)
+	}
+}
+
+func (self *Backend) fallback(line []byte) {
+	if self.Fallback != nil {
+		self.Fallback.Write(append(line, '\n'))
+	}
+}
+
+// Utils
+
+func parseURL(url_ string) (string, string, error) {
+	if url__, err := url.Parse(url_); err == nil {
+		switch url__.Scheme {
+		case "tcp", "tls":
+			return url__.Scheme, url__.Host, nil
+		case "unix":
+			return url__.Scheme, url__.Path, nil
+		default:
+			return "", "", fmt.Errorf("unsupported remote log URL scheme: %s", url__.Scheme)
+		}
+	} else {
+		return "", "", err
+	}
+}
@@ -0,0 +1,81 @@
+package remote
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tliron/commonlog"
+)
+
+// Formats the message as a JSON object in commonlog's own format, which
+// can be parsed by [commonlog.JSONLineParser]. The level, name, text, time,
+// scope, and location use the "_level", "_name", "_message", "_time",
+// "_scope", "_file", and "_line" keys. Value keys that begin with an
+// underscore are escaped (see [commonlog.EscapeJSONKey]), so they cannot
+// collide with these. Values are emitted as native JSON types when
+// possible, with nested values flattened (see [commonlog.FlattenValue]).
+func FormatJSON(message *commonlog.LinearMessage, name []string, level commonlog.Level) []byte {
+	var builder strings.Builder
+
+	builder.WriteRune('{')
+
+	writeKey := func(key string) {
+		if builder.Len() > 1 {
+			builder.WriteRune(',')
+		}
+		builder.Write(jsonString(key))
+		builder.WriteRune(':')
+	}
+
+	writeKey(commonlog.JSONLevelKey)
+	builder.Write(jsonString(strings.ToLower(level.String())))
+
+	if len(name) > 0 {
+		writeKey(commonlog.JSONNameKey)
+		builder.Write(jsonString(strings.Join(name, ".")))
+	}
+
+	if !message.Time.IsZero() {
+		writeKey(commonlog.TIME)
+		builder.Write(jsonString(message.Time.Format(time.RFC3339Nano)))
+	}
+
+	if message.Scope != "" {
+		writeKey(commonlog.SCOPE)
+		builder.Write(jsonString(message.Scope))
+	}
+
+	if message.Message != "" {
+		writeKey(commonlog.MESSAGE)
+		builder.Write(jsonString(message.Message))
+	}
+
+	values := message.FlatValues()
+	for index := range values {
+		value := &values[index]
+		writeKey(commonlog.EscapeJSONKey(value.Key))
+		builder.Write(value.JSON())
+	}
+
+	if message.File != "" {
+		writeKey(commonlog.FILE)
+		builder.Write(jsonString(message.File))
+		if message.Line != -1 {
+			writeKey(commonlog.LINE)
+			builder.WriteString(strconv.FormatInt(message.Line, 10))
+		}
+	}
+
+	builder.WriteRune('}')
+
+	return []byte(builder.String())
+}
+
+// Utils
+
+func jsonString(s string) []byte {
+	bytes, _ := json.Marshal(s)
+	return bytes
+}
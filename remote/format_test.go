@@ -0,0 +1,89 @@
+package remote
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/tliron/commonlog"
+	"github.com/tliron/commonlog/internal/recording"
+)
+
+func TestFormatJSON(t *testing.T) {
+	line := formatTestMessage()
+
+	var object map[string]any
+	if err := json.Unmarshal(line, &object); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]any{
+		commonlog.JSONLevelKey: "error",
+		commonlog.JSONNameKey:  "svc",
+		commonlog.MESSAGE:      "user created",
+		"name":                 "bob",
+		"level":                "debug",
+		"msg":                  "other",
+		"__thread":             float64(3),
+	}
+	if len(object) != len(expected) {
+		t.Errorf("object = %s", line)
+	}
+	for key, value := range expected {
+		if object[key] != value {
+			t.Errorf("%s = %#v, expected %#v", key, object[key], value)
+		}
+	}
+}
+
+func TestFormatJSONRoundTrip(t *testing.T) {
+	backend := recording.Use(t)
+
+	parse := commonlog.JSONLineParser(commonlog.GetLogger("server"), commonlog.Info)
+	parse(string(formatTestMessage())).Send()
+
+	messages := backend.GetMessages()
+	if len(messages) != 1 {
+		t.Fatalf("got %d messages, expected 1", len(messages))
+	}
+
+	message := messages[0]
+	if message.Level != commonlog.Error {
+		t.Errorf("level = %s, expected %s", message.Level, commonlog.Error)
+	}
+	if len(message.Name) != 1 || message.Name[0] != "svc" {
+		t.Errorf("name = %q, expected [svc]", message.Name)
+	}
+	if message.Message.Message != "user created" {
+		t.Errorf("message = %q", message.Message.Message)
+	}
+
+	values := message.GetValues()
+	expected := map[string]any{
+		"name":    "bob",
+		"level":   "debug",
+		"msg":     "other",
+		"_thread": float64(3),
+	}
+	if len(values) != len(expected) {
+		t.Errorf("values = %v", values)
+	}
+	for key, value := range expected {
+		if values[key] != value {
+			t.Errorf("%s = %#v, expected %#v", key, values[key], value)
+		}
+	}
+}
+
+// Utils
+
+// User keys that would collide with the metadata keys.
+func formatTestMessage() []byte {
+	var line []byte
+	message := commonlog.NewLinearMessage(func(message *commonlog.LinearMessage) {
+		line = FormatJSON(message, []string{"svc"}, commonlog.Error)
+	})
+	message.Set(commonlog.MESSAGE, "user created")
+	commonlog.SetMessageKeysAndValues(message, "name", "bob", "level", "debug", "msg", "other", "_thread", 3)
+	message.Send()
+	return line
+}
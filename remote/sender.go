@@ -0,0 +1,227 @@
+package remote
+
+import (
+	"crypto/tls"
+	"net"
+	"sync"
+	"time"
+)
+
+//
+// sender
+//
+
+type sender struct {
+	backend *Backend
+	network string
+	address string
+
+	queue     chan []byte
+	queueLock sync.RWMutex
+	isClosed  bool
+	done      chan struct{}
+	closed    chan struct{}
+	conn      net.Conn
+}
+
+func newSender(backend *Backend, network string, address string) *sender {
+	bufferSize := backend.BufferSize
+	if bufferSize <= 0 {
+		bufferSize = DefaultBufferSize
+	}
+
+	self := sender{
+		backend: backend,
+		network: network,
+		address: address,
+		queue:   make(chan []byte, bufferSize),
+		done:    make(chan struct{}),
+		closed:  make(chan struct{}),
+	}
+
+	go self.run()
+
+	return &self
+}
+
+// Returns false if the buffer is full or if we are closed.
+func (self *sender) enqueue(line []byte) bool {
+	// Note: the queue is closed only with the write lock, so it will not be
+	// closed while we are sending
+	self.queueLock.RLock()
+	defer self.queueLock.RUnlock()
+
+	if self.isClosed {
+		return false
+	}
+
+	select {
+	case self.queue <- line:
+		return true
+	default:
+		return false
+	}
+}
+
+// Waits up to maxFlush for buffered messages to be sent. Messages that
+// could not be sent are written to the fallback writer. A connection or
+// write attempt that is already in progress when maxFlush expires can
+// delay the return by up to DialTimeout.
+func (self *sender) close(maxFlush time.Duration) {
+	self.queueLock.Lock()
+	if self.isClosed {
+		// Already closing
+		self.queueLock.Unlock()
+		<-self.closed
+		return
+	}
+	self.isClosed = true
+	close(self.queue)
+	self.queueLock.Unlock()
+
+	select {
+	case <-self.closed:
+	case <-time.After(maxFlush):
+		close(self.done)
+		<-self.closed
+	}
+}
+
+func (self *sender) run() {
+	defer close(self.closed)
+	defer self.disconnect()
+
+	var pending []byte
+	backoff := time.Duration(0)
+
+	for {
+		if pending == nil {
+			var ok bool
+			if pending, ok = <-self.queue; !ok {
+				return
+			}
+		}
+
+		if self.isDone() {
+			self.drain(pending)
+			return
+		}
+
+		if self.conn == nil {
+			if err := self.connect(); err != nil {
+				backoff = self.nextBackoff(backoff)
+				if !self.wait(backoff) {
+					self.drain(pending)
+					return
+				}
+				continue
+			}
+			backoff = 0
+		}
+
+		if err := self.write(pending); err == nil {
+			pending = nil
+		} else {
+			// Retry with a new connection
+			self.disconnect()
+		}
+	}
+}
+
+func (self *sender) connect() error {
+	timeout := self.timeout()
+
+	var conn net.Conn
+	var err error
+	switch self.network {
+	case "tls":
+		dialer := net.Dialer{Timeout: timeout}
+		conn, err = tls.DialWithDialer(&dialer, "tcp", self.address, self.backend.TLSConfig)
+	default:
+		conn, err = net.DialTimeout(self.network, self.address, timeout)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if token := self.backend.Token; token != "" {
+		if _, err := conn.Write([]byte(token + "\n")); err != nil {
+			conn.Close()
+			return err
+		}
+	}
+
+	self.conn = conn
+	return nil
+}
+
+func (self *sender) write(line []byte) error {
+	if err := self.conn.SetWriteDeadline(time.Now().Add(self.timeout())); err != nil {
+		return err
+	}
+	_, err := self.conn.Write(append(line, '\n'))
+	return err
+}
+
+func (self *sender) timeout() time.Duration {
+	if timeout := self.backend.DialTimeout; timeout > 0 {
+		return timeout
+	}
+	return DefaultDialTimeout
+}
+
+func (self *sender) disconnect() {
+	if self.conn != nil {
+		self.conn.Close()
+		self.conn = nil
+	}
+}
+
+func (self *sender) nextBackoff(backoff time.Duration) time.Duration {
+	minBackoff := self.backend.MinBackoff
+	if minBackoff <= 0 {
+		minBackoff = DefaultMinBackoff
+	}
+
+	maxBackoff := self.backend.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = DefaultMaxBackoff
+	}
+
+	if backoff < minBackoff {
+		return minBackoff
+	} else if backoff *= 2; backoff > maxBackoff {
+		return maxBackoff
+	} else {
+		return backoff
+	}
+}
+
+// Returns false if we should stop trying.
+func (self *sender) wait(backoff time.Duration) bool {
+	select {
+	case <-time.After(backoff):
+		return true
+	case <-self.done:
+		return false
+	}
+}
+
+// Returns true if we should stop trying.
+func (self *sender) isDone() bool {
+	select {
+	case <-self.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Writes the pending line and all buffered lines to the fallback writer.
+func (self *sender) drain(pending []byte) {
+	self.backend.fallback(pending)
+	for line := range self.queue {
+		self.backend.fallback(line)
+	}
+}
@@ -0,0 +1,89 @@
+package remote
+
+import (
+	"bytes"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSenderCloseStalled(t *testing.T) {
+	// A server that accepts connections but never reads from them
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	var conns []net.Conn
+	var connsLock sync.Mutex
+	go func() {
+		for {
+			if conn, err := listener.Accept(); err == nil {
+				connsLock.Lock()
+				conns = append(conns, conn)
+				connsLock.Unlock()
+			} else {
+				return
+			}
+		}
+	}()
+	defer func() {
+		connsLock.Lock()
+		defer connsLock.Unlock()
+		for _, conn := range conns {
+			conn.Close()
+		}
+	}()
+
+	var fallback lineCounter
+	backend := NewBackend()
+	backend.DialTimeout = 100 * time.Millisecond
+	backend.Fallback = &fallback
+
+	sender := newSender(backend, "tcp", listener.Addr().String())
+
+	// Larger than the socket buffers, so that writes cannot complete
+	line := bytes.Repeat([]byte("x"), 1<<26)
+	for range 4 {
+		if !sender.enqueue(line) {
+			fallback.Write(line)
+		}
+	}
+
+	start := time.Now()
+	sender.close(100 * time.Millisecond)
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("close took %s", elapsed)
+	}
+
+	if fallback.getCount() == 0 {
+		t.Error("no lines were written to the fallback")
+	}
+}
+
+//
+// lineCounter
+//
+
+type lineCounter struct {
+	count int
+	lock  sync.Mutex
+}
+
+// ([io.Writer] interface)
+func (self *lineCounter) Write(p []byte) (int, error) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	self.count++
+	return len(p), nil
+}
+
+func (self *lineCounter) getCount() int {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	return self.count
+}
@@ -0,0 +1,23 @@
+package remote
+
+import (
+	"bytes"
+)
+
+//
+// RemoteWriter
+//
+
+// Sends each write as a line of plain text. Note that
+// [commonlog.JSONLineParser] handles lines that are not JSON objects
+// via [commonlog.PlainLineParser].
+type RemoteWriter struct {
+	backend *Backend
+}
+
+// ([io.Writer] interface)
+func (self RemoteWriter) Write(p []byte) (int, error) {
+	line := bytes.TrimRight(p, "\n")
+	self.backend.send(append(line[:0:0], line...))
+	return len(p), nil
+}
@@ -3,6 +3,7 @@ package simple
 import (
 	"io"
 	"os"
+	"time"
 
 	"github.com/tliron/commonlog"
 	"github.com/tliron/go-kutil/terminal"
@@ -30,16 +31,41 @@ type Backend struct {
 	BufferSize int
 	Buffered   bool
 
+	// Time source for message timestamps. Defaults to [time.Now].
+	Now NowFunc
+
+	// Either a [time.Time.Format] layout, such as [time.RFC3339Nano],
+	// or one of the special values [TimeFormatUnixMilli] and
+	// [TimeFormatNone].
+	TimeFormat string
+
+	// When true timestamps will be in UTC, otherwise in local time.
+	UTC bool
+
+	// Limits for flattening nested values (see [commonlog.FlattenValue]).
+	// A FlattenMaxDepth of 0 disables flattening.
+	FlattenMaxDepth int
+	FlattenMaxSize  int
+
+	// How newlines in the message text are rendered. Defaults to
+	// [commonlog.MultilineIndent]. Ignored by [JSONFormat].
+	Multiline commonlog.MultilineMode
+
 	colorize      bool
 	nameHierarchy *commonlog.NameHierarchy
 }
 
 func NewBackend() *Backend {
 	return &Backend{
-		Format:        DefaultFormat,
-		BufferSize:    DefaultBufferSize,
-		Buffered:      true,
-		nameHierarchy: commonlog.NewNameHierarchy(),
+		Format:          DefaultFormat,
+		BufferSize:      DefaultBufferSize,
+		Buffered:        true,
+		Now:             time.Now,
+		TimeFormat:      TimeFormat,
+		FlattenMaxDepth: commonlog.DefaultFlattenMaxDepth,
+		FlattenMaxSize:  commonlog.DefaultFlattenMaxSize,
+		Multiline:       commonlog.MultilineIndent,
+		nameHierarchy:   commonlog.NewNameHierarchy(),
 	}
 }
 
@@ -67,11 +93,11 @@ func (self *Backend) Configure(verbosity int, path *string) {
 		} else {
 			self.colorize = terminal.ColorizeStderr
 			if self.Buffered {
-				writer := util.NewBufferedWriter(os.Stderr, self.BufferSize, false)
+				writer := util.NewBufferedWriter(commonlog.Stderr, self.BufferSize, false)
 				util.OnExitError(writer.Close)
 				self.Writer = writer
 			} else {
-				self.Writer = util.NewSyncedWriter(os.Stderr)
+				self.Writer = util.NewSyncedWriter(commonlog.Stderr)
 			}
 		}
 
@@ -87,10 +113,25 @@ func (self *Backend) GetWriter() io.Writer {
 // ([commonlog.Backend] interface)
 func (self *Backend) NewMessage(level commonlog.Level, depth int, name ...string) commonlog.Message {
 	if self.AllowLevel(level, name...) {
-		return commonlog.TraceMessage(commonlog.NewLinearMessage(func(message *commonlog.LinearMessage) {
-			message_ := self.Format(message, name, level, self.colorize)
+		message := commonlog.NewLinearMessage(func(message *commonlog.LinearMessage) {
+			timestamp := FormatTime(message.Time, self.TimeFormat, self.UTC)
+			message_ := self.Format(message, name, level, timestamp, self.colorize)
 			io.WriteString(self.Writer, message_+"\n")
-		}), depth)
+		})
+
+		message.FlattenMaxDepth = self.FlattenMaxDepth
+		message.FlattenMaxSize = self.FlattenMaxSize
+		message.Multiline = self.Multiline
+
+		// Note: we are taking the timestamp now rather than when formatting,
+		// so that the message will keep its time even if it is sent later
+		if self.Now != nil {
+			message.Time = self.Now()
+		} else {
+			message.Time = time.Now()
+		}
+
+		return commonlog.TraceMessage(message, depth)
 	} else {
 		return nil
 	}
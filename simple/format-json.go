@@ -0,0 +1,85 @@
+package simple
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/tliron/commonlog"
+)
+
+// Formats the message as a single-line JSON object. Values are emitted as
+// native JSON types when possible, with nested values flattened into
+// dotted keys (see [commonlog.FlattenValue]). Newlines in the message
+// text are kept regardless of [Backend.Multiline]. Colorization is ignored.
+//
+// ([FormatFunc] signature)
+func JSONFormat(message *commonlog.LinearMessage, name []string, level commonlog.Level, timestamp string, colorize bool) string {
+	var builder strings.Builder
+
+	builder.WriteRune('{')
+
+	first := true
+	writeKey := func(key string) {
+		if first {
+			first = false
+		} else {
+			builder.WriteRune(',')
+		}
+		builder.WriteString(strconv.Quote(key))
+		builder.WriteRune(':')
+	}
+
+	if timestamp != "" {
+		writeKey("time")
+		if _, err := strconv.ParseInt(timestamp, 10, 64); err == nil {
+			// TimeFormatUnixMilli
+			builder.WriteString(timestamp)
+		} else {
+			builder.Write(jsonString(timestamp))
+		}
+	}
+
+	writeKey("level")
+	builder.Write(jsonString(FormatLevel(level, false)))
+
+	if len(name) > 0 {
+		writeKey("name")
+		builder.Write(jsonString(strings.Join(name, ".")))
+	}
+
+	if message.Scope != "" {
+		writeKey("scope")
+		builder.Write(jsonString(message.Scope))
+	}
+
+	if message.Message != "" {
+		writeKey("message")
+		builder.Write(jsonString(message.Message))
+	}
+
+	values := message.FlatValues()
+	for index := range values {
+		value := &values[index]
+		writeKey(value.Key)
+		builder.Write(value.JSON())
+	}
+
+	if message.File != "" {
+		writeKey("file")
+		builder.Write(jsonString(message.File))
+		if message.Line != -1 {
+			writeKey("line")
+			builder.WriteString(strconv.FormatInt(message.Line, 10))
+		}
+	}
+
+	builder.WriteRune('}')
+
+	return builder.String()
+}
+
+func jsonString(s string) []byte {
+	bytes, _ := json.Marshal(s)
+	return bytes
+}
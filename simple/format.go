@@ -1,6 +1,7 @@
 package simple
 
 import (
+	"strconv"
 	"strings"
 	"time"
 
@@ -8,16 +9,27 @@ import (
 	"github.com/tliron/go-kutil/terminal"
 )
 
-const TimeFormat = "2006/01/02 15:04:05.000"
+const (
+	TimeFormat = "2006/01/02 15:04:05.000"
 
-type FormatFunc func(message *commonlog.LinearMessage, name []string, level commonlog.Level, colorize bool) string
+	// Special [Backend.TimeFormat] value for milliseconds since the Unix epoch.
+	TimeFormatUnixMilli = "unixmilli"
+
+	// Special [Backend.TimeFormat] value for omitting the timestamp.
+	TimeFormatNone = ""
+)
+
+type NowFunc func() time.Time
+
+// The timestamp argument is already formatted and may be empty.
+type FormatFunc func(message *commonlog.LinearMessage, name []string, level commonlog.Level, timestamp string, colorize bool) string
 
 // ([FormatFunc] signature)
-func DefaultFormat(message *commonlog.LinearMessage, name []string, level commonlog.Level, colorize bool) string {
+func DefaultFormat(message *commonlog.LinearMessage, name []string, level commonlog.Level, timestamp string, colorize bool) string {
 	var builder strings.Builder
 
-	if !colorize {
-		builder.WriteString(FormatTime())
+	if !colorize && (timestamp != "") {
+		builder.WriteString(timestamp)
 		builder.WriteRune(' ')
 	}
 
@@ -31,14 +43,16 @@ func DefaultFormat(message *commonlog.LinearMessage, name []string, level common
 	if colorize {
 		s := FormatColorize(builder.String(), level)
 		builder = strings.Builder{}
-		builder.WriteString(FormatTime())
-		builder.WriteRune(' ')
+		if timestamp != "" {
+			builder.WriteString(timestamp)
+			builder.WriteRune(' ')
+		}
 		builder.WriteString(s)
 	}
 
 	if message.Message != "" {
 		builder.WriteRune(' ')
-		builder.WriteString(message.Message)
+		builder.WriteString(message.Multiline.Render(message.Message))
 	}
 
 	if values := message.ValuesString(false); values != "" {
@@ -58,8 +72,27 @@ func DefaultFormat(message *commonlog.LinearMessage, name []string, level common
 	return builder.String()
 }
 
-func FormatTime() string {
-	return time.Now().Format(TimeFormat)
+// Formats the time according to the format, which is either a
+// [time.Time.Format] layout or one of the special values
+// [TimeFormatUnixMilli] and [TimeFormatNone].
+//
+// Returns an empty string for the zero time.
+func FormatTime(time_ time.Time, format string, utc bool) string {
+	if (format == TimeFormatNone) || time_.IsZero() {
+		return ""
+	}
+
+	if format == TimeFormatUnixMilli {
+		return strconv.FormatInt(time_.UnixMilli(), 10)
+	}
+
+	if utc {
+		time_ = time_.UTC()
+	} else {
+		time_ = time_.Local()
+	}
+
+	return time_.Format(format)
 }
 
 func FormatLevel(level commonlog.Level, align bool) string {
@@ -0,0 +1,140 @@
+package sink
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+
+	"github.com/tliron/commonlog"
+)
+
+//
+// CommandCapture
+//
+
+// Forwards the lines of an [exec.Cmd]'s stdout and stderr to a
+// [commonlog.Logger].
+//
+// Each stream has its own level. Lines are parsed by the stream's parse
+// function, if set, otherwise the entire line is used as the message text.
+// All messages get "pid", "command", and "stream" keys.
+//
+// Use [CommandCapture.Start] and [CommandCapture.Wait] instead of the
+// command's own methods, because Wait must only be called after the
+// streams have been fully read.
+type CommandCapture struct {
+	Command       *exec.Cmd
+	Log           commonlog.Logger
+	StdoutLevel   commonlog.Level
+	StderrLevel   commonlog.Level
+	StdoutParse   LineParseFunc
+	StderrParse   LineParseFunc
+	MaxLineLength int
+
+	waitGroup sync.WaitGroup
+}
+
+// Stdout is captured at [commonlog.Info] and stderr at [commonlog.Warning].
+func NewCommandCapture(command *exec.Cmd, log commonlog.Logger) *CommandCapture {
+	return &CommandCapture{
+		Command:       command,
+		Log:           log,
+		StdoutLevel:   commonlog.Info,
+		StderrLevel:   commonlog.Warning,
+		MaxLineLength: commonlog.DefaultMaxLineLength,
+	}
+}
+
+// Convenience function to start the command and wait for it to finish.
+func CaptureCommand(command *exec.Cmd, log commonlog.Logger) error {
+	return NewCommandCapture(command, log).Run()
+}
+
+func (self *CommandCapture) Run() error {
+	if err := self.Start(); err == nil {
+		return self.Wait()
+	} else {
+		return err
+	}
+}
+
+func (self *CommandCapture) Start() error {
+	if (self.Command.Stdout != nil) || (self.Command.Stderr != nil) {
+		return errors.New("command stdout or stderr already set")
+	}
+
+	stdout, err := self.Command.StdoutPipe()
+	if err != nil {
+		return err
+	}
+
+	stderr, err := self.Command.StderrPipe()
+	if err != nil {
+		stdout.Close()
+		return err
+	}
+
+	if err := self.Command.Start(); err != nil {
+		return err
+	}
+
+	pid := self.Command.Process.Pid
+	command := filepath.Base(self.Command.Path)
+
+	self.waitGroup.Add(2)
+	go self.capture(stdout, "stdout", self.StdoutLevel, self.StdoutParse, pid, command)
+	go self.capture(stderr, "stderr", self.StderrLevel, self.StderrParse, pid, command)
+
+	return nil
+}
+
+// Waits for both streams to be fully read and then for the command to
+// exit. See [exec.Cmd.Wait].
+func (self *CommandCapture) Wait() error {
+	self.waitGroup.Wait()
+	return self.Command.Wait()
+}
+
+func (self *CommandCapture) capture(reader io.Reader, stream string, level commonlog.Level, parse LineParseFunc, pid int, command string) {
+	defer self.waitGroup.Done()
+
+	if parse == nil {
+		parse = commonlog.PlainLineParser(self.Log, level)
+	}
+
+	maxLineLength := self.MaxLineLength
+	if maxLineLength <= 0 {
+		maxLineLength = commonlog.DefaultMaxLineLength
+	}
+
+	reader_ := bufio.NewReaderSize(reader, maxLineLength)
+	for {
+		line, truncated, err := commonlog.ReadLine(reader_)
+
+		if (line != "") || (err == nil) {
+			if message := parse(line); message != nil {
+				message.Set("pid", pid)
+				message.Set("command", command)
+				message.Set("stream", stream)
+				if truncated {
+					message.Set("truncated", true)
+				}
+				message.Send()
+			}
+		}
+
+		if err != nil {
+			// The pipe is closed by exec.Cmd.Wait, but we never call it
+			// before we are done, so ErrClosed would only happen if the
+			// caller did
+			if (err != io.EOF) && !errors.Is(err, os.ErrClosed) {
+				self.Log.Errorf("%s: %s", stream, err.Error())
+			}
+			return
+		}
+	}
+}
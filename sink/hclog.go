@@ -1,13 +1,18 @@
 package sink
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	logpkg "log"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 
 	"github.com/hashicorp/go-hclog"
 	"github.com/tliron/commonlog"
+	"github.com/tliron/go-kutil/util"
 )
 
 //
@@ -88,7 +93,7 @@ func (self *HCLogger) ImpliedArgs() []any {
 
 // ([hclog.Logger] interface)
 func (self *HCLogger) With(args ...any) hclog.Logger {
-	return NewHCLogger(args, self.name...)
+	return NewHCLogger(append(self.args[:len(self.args):len(self.args)], args...), self.name...)
 }
 
 // ([hclog.Logger] interface)
@@ -98,7 +103,7 @@ func (self *HCLogger) Name() string {
 
 // ([hclog.Logger] interface)
 func (self *HCLogger) Named(name string) hclog.Logger {
-	return NewHCLogger(self.args, append(self.name, name)...)
+	return NewHCLogger(self.args, append(self.name[:len(self.name):len(self.name)], name)...)
 }
 
 // ([hclog.Logger] interface)
@@ -118,27 +123,159 @@ func (self *HCLogger) GetLevel() hclog.Level {
 
 // ([hclog.Logger] interface)
 func (self *HCLogger) StandardLogger(opts *hclog.StandardLoggerOptions) *logpkg.Logger {
-	// TODO
-	return nil
+	return logpkg.New(self.StandardWriter(opts), "", 0)
 }
 
+// Lines are sent at [hclog.Info] unless the options specify inferring the
+// level from hclog-style prefixes, e.g. "[WARN]", or forcing a level.
+//
 // ([hclog.Logger] interface)
 func (self *HCLogger) StandardWriter(opts *hclog.StandardLoggerOptions) io.Writer {
-	return commonlog.GetWriter()
+	if opts == nil {
+		opts = new(hclog.StandardLoggerOptions)
+	}
+
+	writer := commonlog.NewLoggerWriter(commonlog.NewBackendLogger(self.name...), hcToLevel(hclog.Info))
+	writer.Parse = func(line string) commonlog.Message {
+		line = strings.TrimRight(line, " \t")
+
+		level := hclog.Info
+		if opts.ForceLevel != hclog.NoLevel {
+			// Strip the level prefix, if there is one
+			_, line = hcParseLevelPrefix(line)
+			level = opts.ForceLevel
+		} else if opts.InferLevels {
+			if opts.InferLevelsWithTimestamp {
+				line = line[len(hcTimestampRegexp.FindString(line)):]
+			}
+			level, line = hcParseLevelPrefix(line)
+		}
+
+		return self.newMessage(level, line, nil)
+	}
+
+	return writer
+}
+
+// Creates a [commonlog.LineParseFunc] for lines in hclog's JSON format,
+// e.g. from the stderr of go-plugin plugin processes. The "@level",
+// "@message", "@timestamp", "@module", and "@caller" keys are specially
+// handled, while all other keys are set as is on the message. The module is
+// set as a "module" key, and the caller is set using the "_file" and
+// "_line" keys if [commonlog.Trace] is true. Control characters in keys
+// and values are escaped (see [commonlog.EscapeControlCharactersInKeyValue]).
+//
+// Lines that are not JSON objects are handled by [commonlog.PlainLineParser].
+//
+// ([commonlog.LineParserFunc] signature)
+func HCLogJSONLineParser(log commonlog.Logger, level commonlog.Level) LineParseFunc {
+	plain := commonlog.PlainLineParser(log, level)
+
+	return func(line string) commonlog.Message {
+		trimmed := strings.TrimSpace(line)
+		if !strings.HasPrefix(trimmed, "{") {
+			return plain(line)
+		}
+
+		var object map[string]any
+		if err := json.Unmarshal(util.StringToBytes(trimmed), &object); err != nil {
+			return plain(line)
+		}
+
+		level_ := level
+		if hcLevel, ok := object["@level"].(string); ok {
+			if hcLevel_ := hclog.LevelFromString(hcLevel); hcLevel_ != hclog.NoLevel {
+				level_ = hcToLevel(hcLevel_)
+			}
+		}
+
+		if message := log.NewMessage(level_, 1); message != nil {
+			if message_, ok := object["@message"]; ok {
+				message.Set(commonlog.MESSAGE, commonlog.EscapeControlCharacters(util.ToString(message_)))
+			}
+
+			if timestamp, ok := object["@timestamp"]; ok {
+				_, timestamp = commonlog.EscapeControlCharactersInKeyValue(commonlog.TIME, timestamp)
+				message.Set(commonlog.TIME, timestamp)
+			}
+
+			if module, ok := object["@module"]; ok {
+				message.Set(commonlog.EscapeControlCharactersInKeyValue("module", module))
+			}
+
+			if commonlog.Trace {
+				if caller, ok := object["@caller"].(string); ok {
+					if index := strings.LastIndexByte(caller, ':'); index != -1 {
+						if line_, err := strconv.Atoi(caller[index+1:]); err == nil {
+							message.Set(commonlog.FILE, commonlog.EscapeControlCharacters(caller[:index]))
+							message.Set(commonlog.LINE, line_)
+						}
+					}
+				}
+			}
+
+			keys := make([]string, 0, len(object))
+			for key := range object {
+				if !strings.HasPrefix(key, "@") {
+					keys = append(keys, key)
+				}
+			}
+			slices.Sort(keys)
+
+			for _, key := range keys {
+				message.Set(commonlog.EscapeControlCharactersInKeyValue(key, object[key]))
+			}
+
+			return message
+		} else {
+			return nil
+		}
+	}
 }
 
 // Utils
 
+// See hclog's stdlogAdapter.
+var hcTimestampRegexp = regexp.MustCompile(`^[\d\s\:\/\.\+-TZ]*`)
+
+// See hclog's stdlogAdapter.
+func hcParseLevelPrefix(line string) (hclog.Level, string) {
+	for _, prefix := range hcLevelPrefixes {
+		if strings.HasPrefix(line, prefix.prefix) {
+			return prefix.level, strings.TrimSpace(line[len(prefix.prefix):])
+		}
+	}
+	return hclog.Info, line
+}
+
+var hcLevelPrefixes = []struct {
+	prefix string
+	level  hclog.Level
+}{
+	{"[DEBUG]", hclog.Debug},
+	{"[TRACE]", hclog.Trace},
+	{"[INFO]", hclog.Info},
+	{"[WARN]", hclog.Warn},
+	{"[ERROR]", hclog.Error},
+	{"[ERR]", hclog.Error},
+}
+
 func (self *HCLogger) sendMessage(level hclog.Level, msg string, args []any) {
-	if message := commonlog.NewMessage(hcToLevel(level), 2, self.name...); message != nil {
+	if message := self.newMessage(level, msg, args); message != nil {
+		message.Send()
+	}
+}
+
+func (self *HCLogger) newMessage(level hclog.Level, msg string, args []any) commonlog.Message {
+	if message := commonlog.NewMessage(hcToLevel(level), 3, self.name...); message != nil {
 		message.Set(commonlog.MESSAGE, msg)
 
-		args = append(self.args, args...)
+		args = append(self.args[:len(self.args):len(self.args)], args...)
 		if length := len(args); length%2 == 0 {
 			for i := 0; i < length; i += 2 {
 				if key, ok := args[i].(string); ok {
 					switch key {
-					case commonlog.MESSAGE, commonlog.SCOPE, commonlog.FILE, commonlog.LINE:
+					case commonlog.MESSAGE, commonlog.SCOPE, commonlog.FILE, commonlog.LINE, commonlog.TIME:
 					default:
 						message.Set(key, args[i+1])
 					}
@@ -146,13 +283,15 @@ func (self *HCLogger) sendMessage(level hclog.Level, msg string, args []any) {
 			}
 		}
 
-		message.Send()
+		return message
+	} else {
+		return nil
 	}
 }
 
 func hcToLevel(level hclog.Level) commonlog.Level {
 	switch level {
-	case hclog.NoLevel:
+	case hclog.NoLevel, hclog.Off:
 		return commonlog.None
 	case hclog.Trace:
 		return commonlog.Debug
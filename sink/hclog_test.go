@@ -0,0 +1,40 @@
+package sink
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/tliron/commonlog"
+	"github.com/tliron/commonlog/internal/recording"
+)
+
+func TestHCLogJSONLineParser(t *testing.T) {
+	backend := recording.Use(t)
+
+	parse := HCLogJSONLineParser(commonlog.GetLogger("plugin"), commonlog.Info)
+	parse(`{"@level":"warn","@message":"hello\nworld","@module":"m\nforged",` +
+		`"a\nforged":"b\u001b","c":{"d\nforged":["e\nforged"]}}`).Send()
+
+	messages := backend.GetMessages()
+	if len(messages) != 1 {
+		t.Fatalf("got %d messages, expected 1", len(messages))
+	}
+
+	message := messages[0]
+	if message.Level != commonlog.Warning {
+		t.Errorf("level = %s, expected %s", message.Level, commonlog.Warning)
+	}
+	if text := message.Message.Message; text != `hello\nworld` {
+		t.Errorf("message = %q", text)
+	}
+
+	values := message.GetValues()
+	expected := map[string]any{
+		"module":    `m\nforged`,
+		`a\nforged`: `b\x1b`,
+		"c":         map[string]any{`d\nforged`: []any{`e\nforged`}},
+	}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("values = %#v, expected %#v", values, expected)
+	}
+}
@@ -1,60 +1,306 @@
 package sink
 
 import (
+	"flag"
+	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/tliron/commonlog"
 	"k8s.io/klog/v2"
 )
 
-// Example:
+// Captures klog's text output. Example (the second line is from InfoS):
 //
 //	I0225 00:22:21.901297       1 handler.go:275] Adding GroupVersion tko.nephio.org v1alpha1 to ResourceManager
-
+//	I0225 00:22:21.901297   12345 main.go:31] "Pod status updated" pod="kube-system/kubedns" status="ready"
+//
+// The header's time is kept. Structured key-value pairs are set as message
+// keys. Lines without a header are joined to the preceding entry. Entries
+// that cannot be parsed are sent as is at [commonlog.Info].
 func CaptureKlogOutput(name ...string) {
 	klog.LogToStderr(false)
 
-	klog.SetOutput(NewPipeWriter(func(line string) commonlog.Message {
-		severity := line[0]
-		//month := line[1:3]
-		//day := line[3:5]
-		//hour := line[6:8]
-		//minute := line[9:11]
-		//second := line[12:14]
-		//secondFraction := line[15:20]
-		thread, _ := strconv.Atoi(line[21:29])
-		line = line[30:]
-		before, after, _ := strings.Cut(line, "] ")
-		message := after
-		before, after, _ = strings.Cut(before, ":")
-		file := before
-		lineNo, _ := strconv.Atoi(after)
-
-		var level commonlog.Level
-		switch severity {
-		case 'I':
-			level = commonlog.Info
-		case 'W':
-			level = commonlog.Warning
-		case 'E':
-			level = commonlog.Error
-		case 'F':
-			level = commonlog.Critical
-		}
-
-		if m := commonlog.NewMessage(level, 1, name...); m != nil {
-			m.Set(commonlog.MESSAGE, message)
-
-			if commonlog.Trace {
-				m.Set(commonlog.FILE, file)
-				m.Set(commonlog.LINE, lineNo)
-				m.Set("_thread", thread)
-			}
+	// By default klog also writes errors directly to stderr
+	flags := flag.NewFlagSet("klog", flag.ContinueOnError)
+	klog.InitFlags(flags)
+	flags.Set("stderrthreshold", "FATAL")
+
+	// Note: unless klog's "one_output" is set it will write each entry to
+	// its severity's output *and* to all lower severities' outputs, so we
+	// are giving each severity its own writer, which accepts only entries
+	// of that severity
+	for _, severity := range []byte{'I', 'W', 'E', 'F'} {
+		klog.SetOutputBySeverity(klogSeverityNames[severity], &klogWriter{
+			name:     name,
+			severity: severity,
+		})
+	}
+}
+
+var klogSeverityNames = map[byte]string{
+	'I': "INFO",
+	'W': "WARNING",
+	'E': "ERROR",
+	'F': "FATAL",
+}
+
+//
+// klogWriter
+//
 
-			return m
+// Note: klog writes each entry (which may be multi-line) in a single call
+// to Write.
+type klogWriter struct {
+	name     []string
+	severity byte
+}
+
+// ([io.Writer] interface)
+func (self *klogWriter) Write(p []byte) (int, error) {
+	for _, entry := range splitKlogEntries(string(p)) {
+		header, ok := parseKlogHeader(entry)
+		if ok && (header.severity != self.severity) {
+			// Will be handled by the writer for its severity
+			continue
+		} else if !ok && (self.severity != 'I') {
+			// Will be handled by the writer for info
+			continue
+		}
+
+		if message := newKlogMessage(entry, header, ok, self.name...); message != nil {
+			message.Send()
+		}
+	}
+
+	return len(p), nil
+}
+
+func newKlogMessage(entry string, header klogHeader, ok bool, name ...string) commonlog.Message {
+	if !ok {
+		if message := commonlog.NewMessage(commonlog.Info, 2, name...); message != nil {
+			message.Set(commonlog.MESSAGE, entry)
+			return message
 		} else {
 			return nil
 		}
-	}))
+	}
+
+	var level commonlog.Level
+	switch header.severity {
+	case 'I':
+		level = commonlog.Info
+	case 'W':
+		level = commonlog.Warning
+	case 'E':
+		level = commonlog.Error
+	case 'F':
+		level = commonlog.Critical
+	}
+
+	if message := commonlog.NewMessage(level, 2, name...); message != nil {
+		if text, keysAndValues, ok := parseKlogStructured(header.text); ok {
+			message.Set(commonlog.MESSAGE, text)
+			commonlog.SetMessageKeysAndValues(message, keysAndValues...)
+		} else {
+			message.Set(commonlog.MESSAGE, header.text)
+		}
+
+		if !header.time.IsZero() {
+			message.Set(commonlog.TIME, header.time)
+		}
+
+		if commonlog.Trace {
+			message.Set(commonlog.FILE, header.file)
+			message.Set(commonlog.LINE, header.line)
+			message.Set("_thread", header.thread)
+		}
+
+		return message
+	} else {
+		return nil
+	}
+}
+
+//
+// klogHeader
+//
+
+type klogHeader struct {
+	severity byte
+	time     time.Time
+	thread   int
+	file     string
+	line     int
+	text     string
+}
+
+// Note: the thread ID is padded to 7 characters, but can be longer
+var klogHeaderRegexp = regexp.MustCompile(`(?s)^([IWEF])(\d{4} \d{2}:\d{2}:\d{2}\.\d{6}) +(\d+) ([^\]\n]+?):(\d+)\] ?(.*)$`)
+
+func parseKlogHeader(entry string) (klogHeader, bool) {
+	var header klogHeader
+
+	matches := klogHeaderRegexp.FindStringSubmatch(entry)
+	if matches == nil {
+		return header, false
+	}
+
+	header.severity = matches[1][0]
+	header.time, _ = parseKlogTime(matches[2])
+	header.thread, _ = strconv.Atoi(matches[3])
+	header.file = matches[4]
+	header.line, _ = strconv.Atoi(matches[5])
+	header.text = matches[6]
+
+	return header, true
+}
+
+// Splits the text into entries, where lines without a header are joined
+// to the preceding entry.
+func splitKlogEntries(text string) []string {
+	text = strings.TrimRight(text, "\n")
+	if text == "" {
+		return nil
+	}
+
+	var entries []string
+	var builder strings.Builder
+	for _, line := range strings.Split(text, "\n") {
+		if (builder.Len() > 0) && isKlogHeader(line) {
+			entries = append(entries, builder.String())
+			builder.Reset()
+		} else if builder.Len() > 0 {
+			builder.WriteByte('\n')
+		}
+		builder.WriteString(line)
+	}
+
+	if builder.Len() > 0 {
+		entries = append(entries, builder.String())
+	}
+
+	return entries
+}
+
+func isKlogHeader(line string) bool {
+	// Quick check before using the regexp
+	if (len(line) < 30) || !strings.ContainsRune("IWEF", rune(line[0])) || (line[5] != ' ') {
+		return false
+	}
+	return klogHeaderRegexp.MatchString(line)
+}
+
+const klogTimeLayout = "0102 15:04:05.000000"
+
+// The klog header does not include the year, so we assume the current one.
+func parseKlogTime(s string) (time.Time, error) {
+	if t, err := time.ParseInLocation(klogTimeLayout, s, time.Local); err == nil {
+		return time.Date(time.Now().Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.Local), nil
+	} else {
+		return time.Time{}, err
+	}
+}
+
+// Parses klog's structured text format (used by InfoS and ErrorS), which
+// is a quoted message followed by key=value pairs. Values are either
+// quoted strings, multi-line strings in the form of key=<...>, or bare
+// tokens, which may contain bracketed sections with spaces (e.g. structs
+// formatted with %+v).
+func parseKlogStructured(text string) (string, []any, bool) {
+	if !strings.HasPrefix(text, `"`) {
+		return "", nil, false
+	}
+
+	quoted, err := strconv.QuotedPrefix(text)
+	if err != nil {
+		return "", nil, false
+	}
+
+	message, err := strconv.Unquote(quoted)
+	if err != nil {
+		return "", nil, false
+	}
+
+	var keysAndValues []any
+	rest := text[len(quoted):]
+	for {
+		rest = strings.TrimLeft(rest, " ")
+		if rest == "" {
+			break
+		}
+
+		key, value, ok := strings.Cut(rest, "=")
+		if !ok || (key == "") || strings.ContainsAny(key, " \n\"") {
+			return "", nil, false
+		}
+
+		var value_ any
+		if value_, rest, ok = parseKlogValue(value); !ok {
+			return "", nil, false
+		}
+
+		keysAndValues = append(keysAndValues, key, value_)
+	}
+
+	return message, keysAndValues, true
+}
+
+// Returns the value and the rest of the text.
+func parseKlogValue(text string) (any, string, bool) {
+	switch {
+	case strings.HasPrefix(text, `"`):
+		quoted, err := strconv.QuotedPrefix(text)
+		if err != nil {
+			return nil, "", false
+		}
+		value, err := strconv.Unquote(quoted)
+		if err != nil {
+			return nil, "", false
+		}
+		return value, text[len(quoted):], true
+
+	case strings.HasPrefix(text, "<\n"):
+		// Multi-line value, each line is indented with a tab
+		end := strings.Index(text, "\n >")
+		if end == -1 {
+			return nil, "", false
+		}
+		lines := strings.Split(text[2:end], "\n")
+		for index, line := range lines {
+			lines[index] = strings.TrimPrefix(line, "\t")
+		}
+		return strings.Join(lines, "\n"), text[end+3:], true
+
+	default:
+		depth := 0
+		end := len(text)
+	Scan:
+		for index := 0; index < len(text); index++ {
+			switch text[index] {
+			case '{', '[', '(':
+				depth++
+			case '}', ']', ')':
+				if depth > 0 {
+					depth--
+				}
+			case ' ', '\n':
+				if depth == 0 {
+					end = index
+					break Scan
+				}
+			}
+		}
+
+		token := text[:end]
+		if integer, err := strconv.ParseInt(token, 10, 64); err == nil {
+			return integer, text[end:], true
+		} else if float, err := strconv.ParseFloat(token, 64); err == nil {
+			return float, text[end:], true
+		} else if boolean, err := strconv.ParseBool(token); err == nil {
+			return boolean, text[end:], true
+		}
+		return token, text[end:], true
+	}
 }
@@ -0,0 +1,244 @@
+package sink
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/tliron/commonlog"
+	"github.com/tliron/commonlog/internal/recording"
+)
+
+func TestParseKlogHeader(t *testing.T) {
+	year := time.Now().Year()
+
+	tests := []struct {
+		name   string
+		entry  string
+		ok     bool
+		header klogHeader
+	}{
+		{
+			name:  "info",
+			entry: "I0225 00:22:21.901297       1 handler.go:275] Adding GroupVersion tko.nephio.org v1alpha1 to ResourceManager",
+			ok:    true,
+			header: klogHeader{
+				severity: 'I',
+				time:     time.Date(year, 2, 25, 0, 22, 21, 901297000, time.Local),
+				thread:   1,
+				file:     "handler.go",
+				line:     275,
+				text:     "Adding GroupVersion tko.nephio.org v1alpha1 to ResourceManager",
+			},
+		},
+		{
+			name:  "long thread ID",
+			entry: `E1231 23:59:59.000001 123456789 main.go:31] "Failed" err="boom"`,
+			ok:    true,
+			header: klogHeader{
+				severity: 'E',
+				time:     time.Date(year, 12, 31, 23, 59, 59, 1000, time.Local),
+				thread:   123456789,
+				file:     "main.go",
+				line:     31,
+				text:     `"Failed" err="boom"`,
+			},
+		},
+		{
+			name:  "multi-line",
+			entry: "W0102 03:04:05.000006      42 a/b.go:7] first\nsecond",
+			ok:    true,
+			header: klogHeader{
+				severity: 'W',
+				time:     time.Date(year, 1, 2, 3, 4, 5, 6000, time.Local),
+				thread:   42,
+				file:     "a/b.go",
+				line:     7,
+				text:     "first\nsecond",
+			},
+		},
+		{
+			name:  "unknown severity",
+			entry: "X0225 00:22:21.901297       1 handler.go:275] hello",
+		},
+		{
+			name:  "missing line number",
+			entry: "I0225 00:22:21.901297       1 handler.go] hello",
+		},
+		{
+			name:  "plain text",
+			entry: "hello world",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			header, ok := parseKlogHeader(test.entry)
+			if ok != test.ok {
+				t.Fatalf("ok = %t, expected %t", ok, test.ok)
+			}
+			if !ok {
+				return
+			}
+
+			if !header.time.Equal(test.header.time) {
+				t.Errorf("time = %s, expected %s", header.time, test.header.time)
+			}
+			header.time = test.header.time
+			if header != test.header {
+				t.Errorf("header = %+v, expected %+v", header, test.header)
+			}
+		})
+	}
+}
+
+func TestParseKlogStructured(t *testing.T) {
+	tests := []struct {
+		name          string
+		text          string
+		ok            bool
+		message       string
+		keysAndValues []any
+	}{
+		{
+			name:          "quoted values",
+			text:          `"Pod status updated" pod="kube-system/kubedns" status="ready"`,
+			ok:            true,
+			message:       "Pod status updated",
+			keysAndValues: []any{"pod", "kube-system/kubedns", "status", "ready"},
+		},
+		{
+			name:          "bare values",
+			text:          `"Counted" count=3 ratio=0.5 ok=true name=abc`,
+			ok:            true,
+			message:       "Counted",
+			keysAndValues: []any{"count", int64(3), "ratio", 0.5, "ok", true, "name", "abc"},
+		},
+		{
+			name:          "bracketed value",
+			text:          `"Object" obj={Name:a Namespace:b} list=[1 2 3]`,
+			ok:            true,
+			message:       "Object",
+			keysAndValues: []any{"obj", "{Name:a Namespace:b}", "list", "[1 2 3]"},
+		},
+		{
+			name:          "multi-line value",
+			text:          "\"Config\" data=<\n\tline 1\n\tline 2\n >",
+			ok:            true,
+			message:       "Config",
+			keysAndValues: []any{"data", "line 1\nline 2"},
+		},
+		{
+			name:    "escaped message",
+			text:    `"say \"hi\""`,
+			ok:      true,
+			message: `say "hi"`,
+		},
+		{
+			name: "unquoted message",
+			text: `Pod status updated pod="a"`,
+		},
+		{
+			name: "missing value",
+			text: `"Message" key`,
+		},
+		{
+			name: "unterminated quote",
+			text: `"Message" key="value`,
+		},
+		{
+			name: "unterminated multi-line value",
+			text: "\"Message\" key=<\n\tvalue",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			message, keysAndValues, ok := parseKlogStructured(test.text)
+			if ok != test.ok {
+				t.Fatalf("ok = %t, expected %t", ok, test.ok)
+			}
+			if !ok {
+				return
+			}
+
+			if message != test.message {
+				t.Errorf("message = %q, expected %q", message, test.message)
+			}
+			if !reflect.DeepEqual(keysAndValues, test.keysAndValues) {
+				t.Errorf("keysAndValues = %#v, expected %#v", keysAndValues, test.keysAndValues)
+			}
+		})
+	}
+}
+
+func TestSplitKlogEntries(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		entries []string
+	}{
+		{
+			name: "empty",
+			text: "\n",
+		},
+		{
+			name:    "single",
+			text:    "I0225 00:22:21.901297       1 a.go:1] one\n",
+			entries: []string{"I0225 00:22:21.901297       1 a.go:1] one"},
+		},
+		{
+			name: "continuation lines",
+			text: "I0225 00:22:21.901297       1 a.go:1] one\ncontinued\nE0225 00:22:21.901298       1 a.go:2] two\n",
+			entries: []string{
+				"I0225 00:22:21.901297       1 a.go:1] one\ncontinued",
+				"E0225 00:22:21.901298       1 a.go:2] two",
+			},
+		},
+		{
+			name:    "no header",
+			text:    "panic: oops\n",
+			entries: []string{"panic: oops"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if entries := splitKlogEntries(test.text); !reflect.DeepEqual(entries, test.entries) {
+				t.Errorf("entries = %q, expected %q", entries, test.entries)
+			}
+		})
+	}
+}
+
+func TestKlogWriter(t *testing.T) {
+	backend := recording.Use(t)
+
+	text := []byte("E0225 00:22:21.901297       1 main.go:31] \"Failed\" err=\"boom\"\n")
+
+	// Each writer accepts only entries of its own severity
+	(&klogWriter{name: []string{"test"}, severity: 'I'}).Write(text)
+	if messages := backend.GetMessages(); len(messages) != 0 {
+		t.Fatalf("got %d messages, expected 0", len(messages))
+	}
+
+	(&klogWriter{name: []string{"test"}, severity: 'E'}).Write(text)
+	messages := backend.GetMessages()
+	if len(messages) != 1 {
+		t.Fatalf("got %d messages, expected 1", len(messages))
+	}
+
+	message := messages[0]
+	if message.Level != commonlog.Error {
+		t.Errorf("level = %s, expected %s", message.Level, commonlog.Error)
+	}
+	if message.Message.Message != "Failed" {
+		t.Errorf("message = %q", message.Message.Message)
+	}
+	if err := message.Get("err"); err != "boom" {
+		t.Errorf("err = %#v", err)
+	}
+	if message.Message.Time.IsZero() {
+		t.Error("time not set")
+	}
+}
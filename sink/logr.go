@@ -0,0 +1,130 @@
+package sink
+
+import (
+	"github.com/go-logr/logr"
+	"github.com/tliron/commonlog"
+	"github.com/tliron/go-kutil/util"
+)
+
+const LogrErrorKey = "error"
+
+func NewLogrLogger(name ...string) logr.Logger {
+	return logr.New(NewLogrSink(name...))
+}
+
+//
+// LogrSink
+//
+
+// A [logr.LogSink] that sends messages to the current commonlog backend.
+//
+// [logr.Logger.WithName] appends to the commonlog name. V-levels are
+// mapped via [LogrToLevel] and errors are sent at [commonlog.Error] with an
+// "error" key.
+type LogrSink struct {
+	name          []string
+	keysAndValues []any
+	callDepth     int
+}
+
+func NewLogrSink(name ...string) *LogrSink {
+	return &LogrSink{
+		name: name,
+	}
+}
+
+// ([logr.LogSink] interface)
+func (self *LogrSink) Init(info logr.RuntimeInfo) {
+	self.callDepth = info.CallDepth
+}
+
+// ([logr.LogSink] interface)
+func (self *LogrSink) Enabled(level int) bool {
+	return commonlog.AllowLevel(LogrToLevel(level), self.name...)
+}
+
+// ([logr.LogSink] interface)
+func (self *LogrSink) Info(level int, msg string, keysAndValues ...any) {
+	self.sendMessage(LogrToLevel(level), msg, nil, keysAndValues)
+}
+
+// ([logr.LogSink] interface)
+func (self *LogrSink) Error(err error, msg string, keysAndValues ...any) {
+	self.sendMessage(commonlog.Error, msg, err, keysAndValues)
+}
+
+// ([logr.LogSink] interface)
+func (self *LogrSink) WithValues(keysAndValues ...any) logr.LogSink {
+	self = self.clone()
+	self.keysAndValues = append(self.keysAndValues, keysAndValues...)
+	return self
+}
+
+// ([logr.LogSink] interface)
+func (self *LogrSink) WithName(name string) logr.LogSink {
+	self = self.clone()
+	self.name = append(self.name, commonlog.PathToName(name)...)
+	return self
+}
+
+// ([logr.CallDepthLogSink] interface)
+func (self *LogrSink) WithCallDepth(depth int) logr.LogSink {
+	self = self.clone()
+	self.callDepth += depth
+	return self
+}
+
+func (self *LogrSink) clone() *LogrSink {
+	return &LogrSink{
+		name:          append(self.name[:0:0], self.name...),
+		keysAndValues: append(self.keysAndValues[:0:0], self.keysAndValues...),
+		callDepth:     self.callDepth,
+	}
+}
+
+func (self *LogrSink) sendMessage(level commonlog.Level, msg string, err error, keysAndValues []any) {
+	if message := commonlog.NewMessage(level, self.callDepth+2, self.name...); message != nil {
+		message.Set(commonlog.MESSAGE, msg)
+
+		if err != nil {
+			message.Set(LogrErrorKey, err)
+		}
+
+		logrSet(message, self.keysAndValues)
+		logrSet(message, keysAndValues)
+
+		message.Send()
+	}
+}
+
+// Maps logr V-levels:
+//
+//   - 0: [commonlog.Notice]
+//   - 1: [commonlog.Info]
+//   - 2 and above: [commonlog.Debug]
+func LogrToLevel(level int) commonlog.Level {
+	switch {
+	case level <= 0:
+		return commonlog.Notice
+	case level == 1:
+		return commonlog.Info
+	default:
+		return commonlog.Debug
+	}
+}
+
+// Utils
+
+// Note: logr is lenient about keys and values, so unlike
+// [commonlog.SetMessageKeysAndValues] we will not panic on an odd number.
+func logrSet(message commonlog.Message, keysAndValues []any) {
+	length := len(keysAndValues)
+	for index := 0; index < length; index += 2 {
+		key := util.ToString(keysAndValues[index])
+		if index+1 < length {
+			message.Set(key, keysAndValues[index+1])
+		} else {
+			message.Set(key, nil)
+		}
+	}
+}
@@ -8,7 +8,7 @@ import (
 	"github.com/tliron/go-kutil/util"
 )
 
-type LineParseFunc func(line string) commonlog.Message
+type LineParseFunc = commonlog.LineParseFunc
 
 func NewPipeWriter(parse LineParseFunc, name ...string) io.Writer {
 	pipeReader, pipeWriter := io.Pipe()
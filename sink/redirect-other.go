@@ -0,0 +1,45 @@
+//go:build !linux
+
+package sink
+
+import (
+	"errors"
+	"os"
+
+	"github.com/tliron/commonlog"
+)
+
+//
+// StdioRedirect
+//
+
+type StdioRedirect struct {
+	Log         commonlog.Logger
+	Stdout      bool
+	Stderr      bool
+	StdoutLevel commonlog.Level
+	StderrLevel commonlog.Level
+	StdoutParse LineParseFunc
+	StderrParse LineParseFunc
+
+	OriginalStdout *os.File
+	OriginalStderr *os.File
+}
+
+func NewStdioRedirect(log commonlog.Logger) *StdioRedirect {
+	return &StdioRedirect{
+		Log:         log,
+		Stdout:      true,
+		Stderr:      true,
+		StdoutLevel: commonlog.Notice,
+		StderrLevel: commonlog.Error,
+	}
+}
+
+func (self *StdioRedirect) Start() error {
+	return errors.New("not supported on this platform")
+}
+
+func (self *StdioRedirect) Stop() error {
+	return nil
+}
@@ -0,0 +1,225 @@
+//go:build linux
+
+package sink
+
+import (
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"syscall"
+
+	"github.com/tliron/commonlog"
+	"github.com/tliron/go-kutil/util"
+)
+
+//
+// StdioRedirect
+//
+
+// Redirects the process's own stdout and/or stderr file descriptors (1 and
+// 2) into a [commonlog.Logger]. This captures output that bypasses Go's
+// [os.Stdout] and [os.Stderr], such as from C libraries and the runtime's
+// panic output. All messages get a "stream" key.
+//
+// Note that os.Stdout and os.Stderr still refer to file descriptors 1 and 2,
+// so after [StdioRedirect.Start] writing to them will also be captured. To
+// avoid a feedback loop, Start switches [commonlog.Stdout] and
+// [commonlog.Stderr], which the included backends use, to the original
+// file descriptors. Start will fail if the current backend's writer is
+// detected to be writing directly to file descriptor 1 or 2.
+//
+// Also note that output written right before the process exits (e.g. a
+// panic) might not be forwarded in time.
+type StdioRedirect struct {
+	Log         commonlog.Logger
+	Stdout      bool
+	Stderr      bool
+	StdoutLevel commonlog.Level
+	StderrLevel commonlog.Level
+	StdoutParse LineParseFunc
+	StderrParse LineParseFunc
+
+	// Duplicates of the original file descriptors, valid after
+	// [StdioRedirect.Start] and until [StdioRedirect.Stop].
+	OriginalStdout *os.File
+	OriginalStderr *os.File
+
+	previousStdout *os.File
+	previousStderr *os.File
+	waitGroup      sync.WaitGroup
+	lock           sync.Mutex
+}
+
+// Redirects both stdout (at [commonlog.Notice]) and stderr (at
+// [commonlog.Error]).
+func NewStdioRedirect(log commonlog.Logger) *StdioRedirect {
+	return &StdioRedirect{
+		Log:         log,
+		Stdout:      true,
+		Stderr:      true,
+		StdoutLevel: commonlog.Notice,
+		StderrLevel: commonlog.Error,
+	}
+}
+
+func (self *StdioRedirect) Start() error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	if (self.OriginalStdout != nil) || (self.OriginalStderr != nil) {
+		return errors.New("already started")
+	}
+
+	if backend := commonlog.GetBackend(); backend != nil {
+		if isStdioWriter(backend.GetWriter(), self.Stdout, self.Stderr) {
+			return errors.New("backend writes directly to a redirected file descriptor")
+		}
+	}
+
+	if self.Stdout {
+		if original, err := self.redirect(1, "stdout", self.StdoutLevel, self.StdoutParse); err == nil {
+			self.OriginalStdout = original
+			self.previousStdout = commonlog.Stdout.SetFile(original)
+		} else {
+			return err
+		}
+	}
+
+	if self.Stderr {
+		if original, err := self.redirect(2, "stderr", self.StderrLevel, self.StderrParse); err == nil {
+			self.OriginalStderr = original
+			self.previousStderr = commonlog.Stderr.SetFile(original)
+		} else {
+			self.stop()
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Restores the original file descriptors and waits for all captured output
+// to be forwarded.
+//
+// Child processes that inherited the redirected file descriptors keep
+// them open, in which case this will block until they exit.
+func (self *StdioRedirect) Stop() error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	return self.stop()
+}
+
+// Call with lock.
+func (self *StdioRedirect) stop() error {
+	var errs []error
+
+	if self.previousStdout != nil {
+		commonlog.Stdout.SetFile(self.previousStdout)
+		self.previousStdout = nil
+	}
+
+	if self.previousStderr != nil {
+		commonlog.Stderr.SetFile(self.previousStderr)
+		self.previousStderr = nil
+	}
+
+	if self.OriginalStdout != nil {
+		if err := restore(self.OriginalStdout, 1); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if self.OriginalStderr != nil {
+		if err := restore(self.OriginalStderr, 2); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	// The pipes' write ends are now closed, so the forwarders will get EOF
+	self.waitGroup.Wait()
+
+	if self.OriginalStdout != nil {
+		if err := self.OriginalStdout.Close(); err != nil {
+			errs = append(errs, err)
+		}
+		self.OriginalStdout = nil
+	}
+
+	if self.OriginalStderr != nil {
+		if err := self.OriginalStderr.Close(); err != nil {
+			errs = append(errs, err)
+		}
+		self.OriginalStderr = nil
+	}
+
+	return errors.Join(errs...)
+}
+
+func (self *StdioRedirect) redirect(fd int, stream string, level commonlog.Level, parse LineParseFunc) (*os.File, error) {
+	syscall.ForkLock.Lock()
+	originalFd, err := syscall.Dup(fd)
+	if err == nil {
+		syscall.CloseOnExec(originalFd)
+	}
+	syscall.ForkLock.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	original := os.NewFile(uintptr(originalFd), "/dev/"+stream)
+
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		original.Close()
+		return nil, err
+	}
+
+	// Note: Dup3 (rather than Dup2) is available on all Linux architectures
+	err = syscall.Dup3(int(writer.Fd()), fd, 0)
+	writer.Close()
+	if err != nil {
+		reader.Close()
+		original.Close()
+		return nil, err
+	}
+
+	loggerWriter := commonlog.NewLoggerWriter(commonlog.NewKeyValueLogger(self.Log, "stream", stream), level)
+	if parse != nil {
+		loggerWriter.Parse = func(line string) commonlog.Message {
+			if message := parse(line); message != nil {
+				message.Set("stream", stream)
+				return message
+			} else {
+				return nil
+			}
+		}
+	}
+
+	self.waitGroup.Add(1)
+	go func() {
+		defer self.waitGroup.Done()
+		io.Copy(loggerWriter, reader)
+		loggerWriter.Close()
+		reader.Close()
+	}()
+
+	return original, nil
+}
+
+func restore(original *os.File, fd int) error {
+	return syscall.Dup3(int(original.Fd()), fd, 0)
+}
+
+// Note: we cannot see through all writers (e.g. [util.BufferedWriter]).
+func isStdioWriter(writer io.Writer, stdout bool, stderr bool) bool {
+	switch writer_ := writer.(type) {
+	case *os.File:
+		fd := writer_.Fd()
+		return (stdout && (fd == 1)) || (stderr && (fd == 2))
+	case *util.SyncedWriter:
+		return isStdioWriter(writer_.Writer, stdout, stderr)
+	default:
+		return false
+	}
+}
@@ -0,0 +1,86 @@
+package sink
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+
+	"github.com/tliron/commonlog"
+)
+
+// Implemented by backends that send messages to an slog handler, such as
+// the commonlog slog backend.
+type SlogBackend interface {
+	GetSlogHandler() slog.Handler
+}
+
+// Captures the output of Go's global loggers, both the [log] package's
+// default logger and [slog.Default], into the name. Returns a function
+// that restores the previous state.
+//
+// The log package's lines are parsed using [NewStandardLogParser] with its
+// current flags and prefix. Lines without a level word are sent at
+// [commonlog.Notice].
+//
+// slog's default logger is replaced with one using
+// [StandardStructuredHandler]. However, if the current backend is itself
+// an [SlogBackend] then the slog default is left as is, because it would
+// already be the backend's logger (see the slog backend's Configure), and
+// routing it back through the backend would only add overhead. Moreover,
+// if the backend's handler is slog's built-in default handler, which
+// writes via the log package, then the log package is not captured either,
+// as that would cause a feedback loop.
+func CaptureStandardLibrary(name ...string) func() {
+	previousSlog := slog.Default()
+	previousWriter := log.Writer()
+	previousFlags := log.Flags()
+	previousPrefix := log.Prefix()
+
+	captureSlog := true
+	captureLog := true
+	if slogBackend, ok := commonlog.GetBackend().(SlogBackend); ok {
+		captureSlog = false
+		if handler := slogBackend.GetSlogHandler(); (handler != nil) && isSlogDefaultHandler(handler) {
+			captureLog = false
+		}
+	}
+
+	if captureSlog {
+		// Note: this will also redirect the log package to the handler and
+		// reset its flags, which is why we are setting them below
+		slog.SetDefault(NewStandardStructuredLogger(name...))
+	}
+
+	var pipeWriter io.Writer
+	if captureLog {
+		parse := NewStandardLogParser(previousFlags, previousPrefix)(commonlog.NewBackendLogger(name...), commonlog.Notice)
+		pipeWriter = NewPipeWriter(parse, name...)
+		log.SetOutput(pipeWriter)
+		log.SetFlags(previousFlags)
+		log.SetPrefix(previousPrefix)
+	}
+
+	return func() {
+		if captureSlog {
+			slog.SetDefault(previousSlog)
+		}
+
+		if captureLog {
+			log.SetOutput(previousWriter)
+			log.SetFlags(previousFlags)
+			log.SetPrefix(previousPrefix)
+
+			if closer, ok := pipeWriter.(io.Closer); ok {
+				closer.Close()
+			}
+		}
+	}
+}
+
+// Utils
+
+func isSlogDefaultHandler(handler slog.Handler) bool {
+	// Note: the type is unexported
+	return fmt.Sprintf("%T", handler) == "*slog.defaultHandler"
+}
@@ -2,8 +2,8 @@ package sink
 
 import (
 	contextpkg "context"
-	"fmt"
 	"log/slog"
+	"runtime"
 
 	"github.com/tliron/commonlog"
 )
@@ -16,12 +16,22 @@ func NewStandardStructuredLogger(name ...string) *slog.Logger {
 // StandardStructuredHandler
 //
 
+// An [slog.Handler] that sends records to the current commonlog backend.
+//
+// Attributes in groups are set as message keys with a "." notation, e.g.
+// "group.key". Empty groups are omitted and [slog.LogValuer] values are
+// resolved. Levels are mapped by range (see [SlogToLevel]).
+//
+// When [commonlog.Trace] is true the record's source location is set using
+// the "_file" and "_line" keys.
 type StandardStructuredHandler struct {
 	name []string
 
-	attrs             []slog.Attr
-	currentGroupName  string
-	currentGroupAttrs []slog.Attr
+	// Keys are already prefixed with their groups
+	keysAndValues []any
+
+	// Open groups, each followed by a "."
+	prefix string
 }
 
 func NewStandardStructuredHandler(name ...string) *StandardStructuredHandler {
@@ -32,19 +42,35 @@ func NewStandardStructuredHandler(name ...string) *StandardStructuredHandler {
 
 // ([slog.Handler] interface)
 func (self *StandardStructuredHandler) Enabled(context contextpkg.Context, level slog.Level) bool {
-	return commonlog.AllowLevel(slogToLevel(level), self.name...)
+	return commonlog.AllowLevel(SlogToLevel(level), self.name...)
 }
 
 // ([slog.Handler] interface)
 func (self *StandardStructuredHandler) Handle(context contextpkg.Context, record slog.Record) error {
-	if message := commonlog.NewMessage(slogToLevel(record.Level), 2, self.name...); message != nil {
+	if message := commonlog.NewMessage(SlogToLevel(record.Level), 2, self.name...); message != nil {
 		message.Set(commonlog.MESSAGE, record.Message)
 
-		self.resolve(false)
-		for _, attr := range self.attrs {
-			slogSet(message, "", attr)
+		if !record.Time.IsZero() {
+			message.Set(commonlog.TIME, record.Time)
 		}
 
+		if commonlog.Trace && (record.PC != 0) {
+			frame, _ := runtime.CallersFrames([]uintptr{record.PC}).Next()
+			if frame.File != "" {
+				message.Set(commonlog.FILE, frame.File)
+				message.Set(commonlog.LINE, frame.Line)
+			}
+		}
+
+		commonlog.SetMessageKeysAndValues(message, self.keysAndValues...)
+
+		var keysAndValues []any
+		record.Attrs(func(attr slog.Attr) bool {
+			keysAndValues = appendSlogAttr(keysAndValues, self.prefix, attr)
+			return true
+		})
+		commonlog.SetMessageKeysAndValues(message, keysAndValues...)
+
 		message.Send()
 	}
 
@@ -58,10 +84,8 @@ func (self *StandardStructuredHandler) WithAttrs(attrs []slog.Attr) slog.Handler
 	}
 
 	self = self.clone()
-	if self.currentGroupName == "" {
-		self.attrs = append(self.attrs, attrs...)
-	} else {
-		self.currentGroupAttrs = append(self.currentGroupAttrs, attrs...)
+	for _, attr := range attrs {
+		self.keysAndValues = appendSlogAttr(self.keysAndValues, self.prefix, attr)
 	}
 
 	return self
@@ -74,73 +98,67 @@ func (self *StandardStructuredHandler) WithGroup(name string) slog.Handler {
 	}
 
 	self = self.clone()
-	self.resolve(true)
-	self.currentGroupName = name
+	self.prefix += name + "."
 
 	return self
 }
 
 func (self *StandardStructuredHandler) clone() *StandardStructuredHandler {
 	handler := NewStandardStructuredHandler(self.name...)
-
-	handler.attrs = append(self.attrs[:0:0], self.attrs...)
-
-	handler.currentGroupName = self.currentGroupName
-	handler.currentGroupAttrs = append(self.currentGroupAttrs[:0:0], self.currentGroupAttrs...)
-
+	handler.keysAndValues = append(self.keysAndValues[:0:0], self.keysAndValues...)
+	handler.prefix = self.prefix
 	return handler
 }
 
-func (self *StandardStructuredHandler) resolve(closeGroup bool) {
-	if self.currentGroupName != "" {
-		if len(self.currentGroupAttrs) > 0 {
-			self.attrs = append(self.attrs, slog.Attr{
-				Key:   self.currentGroupName,
-				Value: slog.GroupValue(self.currentGroupAttrs...),
-			})
-			self.currentGroupAttrs = nil
-		}
-
-		if closeGroup {
-			self.currentGroupName = ""
-		}
-	}
-}
-
-// Utils
-
-func slogToLevel(level slog.Level) commonlog.Level {
-	switch level {
-	case slog.LevelDebug:
+// Maps slog levels by range (compatible with the custom levels of the
+// commonlog slog backend):
+//
+//   - below [slog.LevelInfo]: [commonlog.Debug]
+//   - below [slog.LevelInfo]+2: [commonlog.Info]
+//   - below [slog.LevelWarn]: [commonlog.Notice]
+//   - below [slog.LevelError]: [commonlog.Warning]
+//   - below [slog.LevelError]+4: [commonlog.Error]
+//   - [slog.LevelError]+4 and above: [commonlog.Critical]
+func SlogToLevel(level slog.Level) commonlog.Level {
+	switch {
+	case level < slog.LevelInfo:
 		return commonlog.Debug
-	case slog.LevelInfo:
+	case level < slog.LevelInfo+2:
 		return commonlog.Info
-	case slog.LevelWarn:
+	case level < slog.LevelWarn:
+		return commonlog.Notice
+	case level < slog.LevelError:
 		return commonlog.Warning
-	case slog.LevelError:
+	case level < slog.LevelError+4:
 		return commonlog.Error
 	default:
-		panic(fmt.Sprintf("unsupported log level: %d", level))
+		return commonlog.Critical
 	}
 }
 
-func slogSet(message commonlog.Message, prefix string, attr slog.Attr) {
+// Utils
+
+func appendSlogAttr(keysAndValues []any, prefix string, attr slog.Attr) []any {
+	attr.Value = attr.Value.Resolve()
+
 	switch attr.Value.Kind() {
 	case slog.KindGroup:
-		if prefix != "" {
-			prefix += "." + prefix
+		if attr.Key != "" {
+			prefix += attr.Key + "."
 		}
 
+		// Note: empty groups will add nothing
 		for _, attr_ := range attr.Value.Group() {
-			slogSet(message, prefix, attr_)
+			keysAndValues = appendSlogAttr(keysAndValues, prefix, attr_)
 		}
 
 	default:
-		value := attr.Value.Resolve().Any()
-		if prefix == "" {
-			message.Set(attr.Key, value)
-		} else {
-			message.Set(prefix+attr.Key, value)
+		if attr.Equal(slog.Attr{}) {
+			return keysAndValues
 		}
+
+		keysAndValues = append(keysAndValues, prefix+attr.Key, attr.Value.Any())
 	}
+
+	return keysAndValues
 }
@@ -0,0 +1,75 @@
+package sink
+
+import (
+	"log/slog"
+	"strings"
+	"testing"
+	"testing/slogtest"
+
+	"github.com/tliron/commonlog"
+	"github.com/tliron/commonlog/internal/recording"
+)
+
+func TestStandardStructuredHandler(t *testing.T) {
+	backend := recording.Use(t)
+
+	results := func() []map[string]any {
+		var results []map[string]any
+		for _, message := range backend.GetMessages() {
+			result := map[string]any{
+				slog.LevelKey:   message.Level,
+				slog.MessageKey: message.Message.Message,
+			}
+
+			if !message.Message.Time.IsZero() {
+				result[slog.TimeKey] = message.Message.Time
+			}
+
+			// Groups are flattened into "group.key"
+			for _, value := range message.Message.Values {
+				path := strings.Split(value.Key, ".")
+				map_ := result
+				for _, group := range path[:len(path)-1] {
+					if group_, ok := map_[group].(map[string]any); ok {
+						map_ = group_
+					} else {
+						group_ = make(map[string]any)
+						map_[group] = group_
+						map_ = group_
+					}
+				}
+				map_[path[len(path)-1]] = value.Value
+			}
+
+			results = append(results, result)
+		}
+		return results
+	}
+
+	if err := slogtest.TestHandler(NewStandardStructuredHandler("test"), results); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSlogToLevel(t *testing.T) {
+	tests := []struct {
+		level    slog.Level
+		expected commonlog.Level
+	}{
+		{slog.LevelDebug - 4, commonlog.Debug},
+		{slog.LevelDebug, commonlog.Debug},
+		{slog.LevelInfo, commonlog.Info},
+		{slog.LevelInfo + 1, commonlog.Info},
+		{slog.LevelInfo + 2, commonlog.Notice},
+		{slog.LevelWarn, commonlog.Warning},
+		{slog.LevelError, commonlog.Error},
+		{slog.LevelError + 4, commonlog.Critical},
+		{slog.LevelError + 8, commonlog.Critical},
+	}
+
+	for _, test := range tests {
+		if level := SlogToLevel(test.level); level != test.expected {
+			t.Errorf("SlogToLevel(%s) = %s, expected %s", test.level, level, test.expected)
+		}
+	}
+}
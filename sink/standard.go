@@ -2,21 +2,194 @@ package sink
 
 import (
 	"log"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/tliron/commonlog"
 )
 
+const (
+	StandardLogDateLayout = "2006/01/02"
+	StandardLogTimeLayout = "15:04:05"
+)
+
+// Creates a [log.Logger] that sends all lines to the parse function. The
+// logger has no prefix and no flags.
 func NewStandardLogger(parse LineParseFunc) *log.Logger {
-	return log.New(NewPipeWriter(StandardLogParser), "", 0)
+	return log.New(NewPipeWriter(parse), "", 0)
 }
 
-// TODO.
+// Creates a [log.Logger] with the flags and prefix, which sends all lines
+// to the log via [NewStandardLogParser]. Lines without a level word are
+// sent at the level.
+func NewStandardLoggerWithFlags(flags int, prefix string, log_ commonlog.Logger, level commonlog.Level) *log.Logger {
+	return log.New(NewPipeWriter(NewStandardLogParser(flags, prefix)(log_, level)), prefix, flags)
+}
+
+var standardLogParser = NewStandardLogParser(log.LstdFlags, "")(commonlog.NewBackendLogger(), commonlog.Notice)
+
+// Parses lines written by a [log.Logger] with the default flags
+// ([log.LstdFlags]) and no prefix. Example:
 //
-// Should take into account the logger flags. Example:
+//	2023/10/21 11:15:46 Closing the StdScheduler.
 //
-//	INFO 2023/10/21 11:15:46 simple_logger.go:73: Closing the StdScheduler.
+// Lines without a level word are sent at [commonlog.Notice].
 //
-// [LogParseFunc] signature
+// ([LineParseFunc] signature)
 func StandardLogParser(line string) commonlog.Message {
-	return nil
+	return standardLogParser(line)
+}
+
+// Creates a [commonlog.LineParserFunc] for lines written by a [log.Logger]
+// with the flags and prefix. Example for flags [log.LstdFlags] |
+// [log.Lshortfile] and prefix "INFO ":
+//
+//	INFO 2023/10/21 11:15:46 simple_logger.go:73: Closing the StdScheduler.
+//
+// The time, file, and line are set on the message using the special keys.
+// Note that [log.Ltime] without [log.Ldate] results in a time on the
+// current date.
+//
+// The level is detected from a level word (see [commonlog.ParseLevelPrefix])
+// in the prefix or at the beginning of the message text, e.g. "[WARN]",
+// which is then removed from the text. Lines that do not match the flags are
+// sent as is.
+func NewStandardLogParser(flags int, prefix string) commonlog.LineParserFunc {
+	prefixLevel, _, prefixHasLevel := commonlog.ParseLevelPrefix(prefix)
+
+	return func(log_ commonlog.Logger, level commonlog.Level) LineParseFunc {
+		plain := commonlog.PlainLineParser(log_, level)
+
+		return func(line string) commonlog.Message {
+			header, ok := parseStandardLogHeader(line, flags, prefix)
+			if !ok {
+				return plain(line)
+			}
+
+			level_ := level
+			if prefixHasLevel {
+				level_ = prefixLevel
+			}
+
+			text := header.text
+			if level__, rest, ok := commonlog.ParseLevelPrefix(text); ok {
+				level_ = level__
+				text = rest
+			}
+
+			if message := log_.NewMessage(level_, 1); message != nil {
+				message.Set(commonlog.MESSAGE, commonlog.EscapeControlCharacters(text))
+				if !header.time.IsZero() {
+					message.Set(commonlog.TIME, header.time)
+				}
+				if header.file != "" {
+					message.Set(commonlog.FILE, header.file)
+					message.Set(commonlog.LINE, header.line)
+				}
+				return message
+			} else {
+				return nil
+			}
+		}
+	}
+}
+
+type standardLogHeader struct {
+	time time.Time
+	file string
+	line int
+	text string
+}
+
+// See log.Logger.formatHeader.
+func parseStandardLogHeader(line string, flags int, prefix string) (standardLogHeader, bool) {
+	var header standardLogHeader
+
+	msgPrefix := flags&log.Lmsgprefix != 0
+	if !msgPrefix {
+		if !strings.HasPrefix(line, prefix) {
+			return header, false
+		}
+		line = line[len(prefix):]
+	}
+
+	location := time.Local
+	if flags&log.LUTC != 0 {
+		location = time.UTC
+	}
+
+	if flags&(log.Ldate|log.Ltime|log.Lmicroseconds) != 0 {
+		var layout string
+		if flags&log.Ldate != 0 {
+			layout = StandardLogDateLayout
+		}
+		if flags&(log.Ltime|log.Lmicroseconds) != 0 {
+			if layout != "" {
+				layout += " "
+			}
+			layout += StandardLogTimeLayout
+			if flags&log.Lmicroseconds != 0 {
+				layout += ".000000"
+			}
+		}
+
+		// Layout length equals formatted length for these layouts
+		length := len(layout)
+		if (len(line) <= length) || (line[length] != ' ') {
+			return header, false
+		}
+
+		time_, err := time.ParseInLocation(layout, line[:length], location)
+		if err != nil {
+			return header, false
+		}
+
+		if flags&log.Ldate == 0 {
+			now := time.Now().In(location)
+			time_ = time.Date(now.Year(), now.Month(), now.Day(), time_.Hour(), time_.Minute(), time_.Second(), time_.Nanosecond(), location)
+		}
+
+		header.time = time_
+		line = line[length+1:]
+	}
+
+	if flags&(log.Llongfile|log.Lshortfile) != 0 {
+		// The file might contain colons (e.g. Windows drive letters), so
+		// we will look for the first ":<digits>: "
+		found := false
+		for start := 0; ; {
+			index := strings.Index(line[start:], ": ")
+			if index == -1 {
+				break
+			}
+			index += start
+
+			if colon := strings.LastIndexByte(line[:index], ':'); colon != -1 {
+				if line_, err := strconv.Atoi(line[colon+1 : index]); err == nil {
+					header.file = line[:colon]
+					header.line = line_
+					line = line[index+2:]
+					found = true
+					break
+				}
+			}
+
+			start = index + 2
+		}
+
+		if !found {
+			return header, false
+		}
+	}
+
+	if msgPrefix {
+		if !strings.HasPrefix(line, prefix) {
+			return header, false
+		}
+		line = line[len(prefix):]
+	}
+
+	header.text = line
+	return header, true
 }
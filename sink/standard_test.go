@@ -0,0 +1,161 @@
+package sink
+
+import (
+	"log"
+	"testing"
+	"time"
+
+	"github.com/tliron/commonlog"
+	"github.com/tliron/commonlog/internal/recording"
+)
+
+func TestParseStandardLogHeader(t *testing.T) {
+	tests := []struct {
+		name   string
+		line   string
+		flags  int
+		prefix string
+		ok     bool
+		time   time.Time
+		file   string
+		line_  int
+		text   string
+	}{
+		{
+			name: "no flags",
+			line: "hello world",
+			ok:   true,
+			text: "hello world",
+		},
+		{
+			name:  "standard flags",
+			line:  "2023/10/21 11:15:46 Closing the StdScheduler.",
+			flags: log.LstdFlags,
+			ok:    true,
+			time:  time.Date(2023, 10, 21, 11, 15, 46, 0, time.Local),
+			text:  "Closing the StdScheduler.",
+		},
+		{
+			name:  "microseconds in UTC",
+			line:  "2023/10/21 11:15:46.123456 hello",
+			flags: log.LstdFlags | log.Lmicroseconds | log.LUTC,
+			ok:    true,
+			time:  time.Date(2023, 10, 21, 11, 15, 46, 123456000, time.UTC),
+			text:  "hello",
+		},
+		{
+			name:  "date only",
+			line:  "2023/10/21 hello",
+			flags: log.Ldate,
+			ok:    true,
+			time:  time.Date(2023, 10, 21, 0, 0, 0, 0, time.Local),
+			text:  "hello",
+		},
+		{
+			name:   "prefix and short file",
+			line:   "INFO 2023/10/21 11:15:46 simple_logger.go:73: Closing the StdScheduler.",
+			flags:  log.LstdFlags | log.Lshortfile,
+			prefix: "INFO ",
+			ok:     true,
+			time:   time.Date(2023, 10, 21, 11, 15, 46, 0, time.Local),
+			file:   "simple_logger.go",
+			line_:  73,
+			text:   "Closing the StdScheduler.",
+		},
+		{
+			name:  "long file with colons",
+			line:  `C:\src\main.go:12: a: b`,
+			flags: log.Llongfile,
+			ok:    true,
+			file:  `C:\src\main.go`,
+			line_: 12,
+			text:  "a: b",
+		},
+		{
+			name:   "message prefix",
+			line:   "2023/10/21 11:15:46 [app] hello",
+			flags:  log.LstdFlags | log.Lmsgprefix,
+			prefix: "[app] ",
+			ok:     true,
+			time:   time.Date(2023, 10, 21, 11, 15, 46, 0, time.Local),
+			text:   "hello",
+		},
+		{
+			name:   "missing prefix",
+			line:   "2023/10/21 11:15:46 hello",
+			flags:  log.LstdFlags,
+			prefix: "INFO ",
+		},
+		{
+			name:  "missing time",
+			line:  "hello",
+			flags: log.LstdFlags,
+		},
+		{
+			name:  "malformed time",
+			line:  "2023/13/45 11:15:46 hello",
+			flags: log.LstdFlags,
+		},
+		{
+			name:  "missing file",
+			line:  "hello world",
+			flags: log.Lshortfile,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			header, ok := parseStandardLogHeader(test.line, test.flags, test.prefix)
+			if ok != test.ok {
+				t.Fatalf("ok = %t, expected %t", ok, test.ok)
+			}
+			if !ok {
+				return
+			}
+
+			if !header.time.Equal(test.time) {
+				t.Errorf("time = %s, expected %s", header.time, test.time)
+			}
+			if header.file != test.file {
+				t.Errorf("file = %q, expected %q", header.file, test.file)
+			}
+			if header.line != test.line_ {
+				t.Errorf("line = %d, expected %d", header.line, test.line_)
+			}
+			if header.text != test.text {
+				t.Errorf("text = %q, expected %q", header.text, test.text)
+			}
+		})
+	}
+}
+
+func TestNewStandardLoggerWithFlags(t *testing.T) {
+	backend := recording.Use(t)
+
+	logger := NewStandardLoggerWithFlags(log.LstdFlags|log.Lshortfile, "", commonlog.GetLogger("test"), commonlog.Info)
+	logger.Print("[WARN] something happened")
+	logger.Print("just info")
+
+	// Note: the pipe writer is asynchronous
+	messages := backend.WaitForMessages(t, 2)
+
+	if messages[0].Level != commonlog.Warning {
+		t.Errorf("level = %s, expected %s", messages[0].Level, commonlog.Warning)
+	}
+	if text := messages[0].Message.Message; text != "something happened" {
+		t.Errorf("text = %q", text)
+	}
+	if file := messages[0].Message.File; file != "standard_test.go" {
+		t.Errorf("file = %q", file)
+	}
+	if messages[0].Message.Time.IsZero() {
+		t.Error("time not set")
+	}
+
+	if messages[1].Level != commonlog.Info {
+		t.Errorf("level = %s, expected %s", messages[1].Level, commonlog.Info)
+	}
+	if text := messages[1].Message.Message; text != "just info" {
+		t.Errorf("text = %q", text)
+	}
+}
@@ -6,6 +6,9 @@ import (
 	"io"
 	"log/slog"
 	"os"
+	"runtime"
+	"strings"
+	"time"
 
 	"github.com/tliron/commonlog"
 	"github.com/tliron/go-kutil/util"
@@ -14,6 +17,14 @@ import (
 const (
 	LogFileWritePermissions = 0600
 	DefaultBufferSize       = 1_000
+	DefaultNameKey          = "name"
+	DefaultScopeKey         = "scope"
+
+	// Between [slog.LevelInfo] and [slog.LevelWarn].
+	LevelNotice = slog.LevelInfo + 2
+
+	// Above [slog.LevelError].
+	LevelCritical = slog.LevelError + 4
 )
 
 func init() {
@@ -26,12 +37,34 @@ func init() {
 // Backend
 //
 
+// Sends messages to an [slog.Logger].
+//
+// By default Configure creates an [slog.TextHandler] (or an
+// [slog.JSONHandler] if JSON is true) that writes to stderr or to the log
+// file. Alternatively, set Handler before calling Configure in order to
+// wrap any handler, in which case Writer, JSON, and ReplaceAttr are
+// ignored. Either way, the logger becomes [slog.Default].
+//
+// The name is added as an attribute with NameKey. If GroupByName is true
+// then the message's attributes are also put in a group with the name. The
+// "_scope" key is renamed to ScopeKey.
+//
+// Levels are mapped to slog levels, with the custom [LevelNotice] and
+// [LevelCritical], which are named "NOTICE" and "CRITICAL" by our handlers.
 type Backend struct {
-	Logger     *slog.Logger
-	Writer     io.Writer
-	BufferSize int
-	Buffered   bool
-	AddSource  bool
+	Logger      *slog.Logger
+	Handler     slog.Handler
+	Writer      io.Writer
+	BufferSize  int
+	Buffered    bool
+	AddSource   bool
+	JSON        bool
+	NameKey     string
+	ScopeKey    string
+	GroupByName bool
+
+	// Called after we replace the level names. See [slog.HandlerOptions].
+	ReplaceAttr func(groups []string, attr slog.Attr) slog.Attr
 
 	nameHierarchy *commonlog.NameHierarchy
 }
@@ -40,6 +73,8 @@ func NewBackend() *Backend {
 	return &Backend{
 		BufferSize:    DefaultBufferSize,
 		Buffered:      true,
+		NameKey:       DefaultNameKey,
+		ScopeKey:      DefaultScopeKey,
 		nameHierarchy: commonlog.NewNameHierarchy(),
 	}
 }
@@ -53,33 +88,45 @@ func (self *Backend) Configure(verbosity int, path *string) {
 		self.Logger = slog.New(MOCK_HANDLER)
 		self.nameHierarchy.SetMaxLevel(commonlog.None)
 	} else {
-		if path != nil {
-			if file, err := os.OpenFile(*path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, LogFileWritePermissions); err == nil {
-				util.OnExitError(file.Close)
-				if self.Buffered {
-					// Note: slog.NewTextHandler modifies its buffers, so we must copy byte slices
-					writer := util.NewBufferedWriter(file, self.BufferSize, true)
-					util.OnExitError(writer.Close)
-					self.Writer = writer
+		if self.Handler != nil {
+			self.Writer = nil
+			self.Logger = slog.New(self.Handler)
+		} else {
+			if path != nil {
+				if file, err := os.OpenFile(*path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, LogFileWritePermissions); err == nil {
+					util.OnExitError(file.Close)
+					if self.Buffered {
+						// Note: slog.NewTextHandler modifies its buffers, so we must copy byte slices
+						writer := util.NewBufferedWriter(file, self.BufferSize, true)
+						util.OnExitError(writer.Close)
+						self.Writer = writer
+					} else {
+						self.Writer = util.NewSyncedWriter(file)
+					}
 				} else {
-					self.Writer = util.NewSyncedWriter(file)
+					util.Failf("log file error: %s", err.Error())
 				}
+			} else if self.Buffered {
+				// Note: slog.NewTextHandler modifies its buffers, so we must copy byte slices
+				writer := util.NewBufferedWriter(commonlog.Stderr, self.BufferSize, true)
+				util.OnExitError(writer.Close)
+				self.Writer = writer
 			} else {
-				util.Failf("log file error: %s", err.Error())
+				self.Writer = util.NewSyncedWriter(commonlog.Stderr)
 			}
-		} else if self.Buffered {
-			// Note: slog.NewTextHandler modifies its buffers, so we must copy byte slices
-			writer := util.NewBufferedWriter(os.Stderr, self.BufferSize, true)
-			util.OnExitError(writer.Close)
-			self.Writer = writer
-		} else {
-			self.Writer = util.NewSyncedWriter(os.Stderr)
-		}
 
-		self.Logger = slog.New(slog.NewTextHandler(self.Writer, &slog.HandlerOptions{
-			AddSource: self.AddSource,
-			Level:     slog.LevelDebug,
-		}))
+			options := slog.HandlerOptions{
+				AddSource:   self.AddSource,
+				Level:       slog.LevelDebug,
+				ReplaceAttr: self.replaceAttr,
+			}
+
+			if self.JSON {
+				self.Logger = slog.New(slog.NewJSONHandler(self.Writer, &options))
+			} else {
+				self.Logger = slog.New(slog.NewTextHandler(self.Writer, &options))
+			}
+		}
 
 		self.nameHierarchy.SetMaxLevel(maxLevel)
 	}
@@ -92,28 +139,44 @@ func (self *Backend) GetWriter() io.Writer {
 	return self.Writer
 }
 
+// Gets the slog handler used for our messages. Can be nil if not
+// configured.
+func (self *Backend) GetSlogHandler() slog.Handler {
+	if self.Logger != nil {
+		return self.Logger.Handler()
+	} else {
+		return nil
+	}
+}
+
 // ([commonlog.Backend] interface)
 func (self *Backend) NewMessage(level commonlog.Level, depth int, name ...string) commonlog.Message {
 	if (self.Logger != nil) && self.AllowLevel(level, name...) {
-		var slogLevel slog.Level
-		switch level {
-		case commonlog.Critical:
-			slogLevel = slog.LevelError
-		case commonlog.Error:
-			slogLevel = slog.LevelError
-		case commonlog.Warning:
-			slogLevel = slog.LevelWarn
-		case commonlog.Notice:
-			slogLevel = slog.LevelInfo
-		case commonlog.Info:
-			slogLevel = slog.LevelInfo
-		case commonlog.Debug:
-			slogLevel = slog.LevelDebug
-		default:
-			panic(fmt.Sprintf("unsupported log level: %d", level))
+		slogLevel := LevelToSlog(level)
+
+		var pc uintptr
+		if self.AddSource {
+			var pcs [1]uintptr
+			if runtime.Callers(depth+2, pcs[:]) == 1 {
+				pc = pcs[0]
+			}
+		}
+
+		message := NewMessage(self.Logger, slogLevel, context.Background(), time.Now(), pc).(*Message)
+		if self.ScopeKey != "" {
+			message.scopeKey = self.ScopeKey
+		}
+
+		// Note: we are adding the name to the record rather than using
+		// slog.Logger.With and slog.Logger.WithGroup, which would create
+		// new handlers for every message
+		if name := strings.Join(name, "."); name != "" {
+			message.nameKey = self.NameKey
+			message.name = name
+			message.groupByName = self.GroupByName
 		}
 
-		return commonlog.TraceMessage(NewMessage(self.Logger, slogLevel, context.Background()), depth)
+		return commonlog.TraceMessage(message, depth)
 	} else {
 		return nil
 	}
@@ -133,3 +196,50 @@ func (self *Backend) SetMaxLevel(level commonlog.Level, name ...string) {
 func (self *Backend) GetMaxLevel(name ...string) commonlog.Level {
 	return self.nameHierarchy.GetMaxLevel(name...)
 }
+
+// ([slog.HandlerOptions] ReplaceAttr)
+func (self *Backend) replaceAttr(groups []string, attr slog.Attr) slog.Attr {
+	if (len(groups) == 0) && (attr.Key == slog.LevelKey) {
+		if level, ok := attr.Value.Any().(slog.Level); ok {
+			attr.Value = slog.StringValue(LevelName(level))
+		}
+	}
+
+	if self.ReplaceAttr != nil {
+		attr = self.ReplaceAttr(groups, attr)
+	}
+
+	return attr
+}
+
+func LevelToSlog(level commonlog.Level) slog.Level {
+	switch level {
+	case commonlog.Critical:
+		return LevelCritical
+	case commonlog.Error:
+		return slog.LevelError
+	case commonlog.Warning:
+		return slog.LevelWarn
+	case commonlog.Notice:
+		return LevelNotice
+	case commonlog.Info:
+		return slog.LevelInfo
+	case commonlog.Debug:
+		return slog.LevelDebug
+	default:
+		panic(fmt.Sprintf("unsupported log level: %d", level))
+	}
+}
+
+// Like [slog.Level.String] but with names for [LevelNotice] and
+// [LevelCritical].
+func LevelName(level slog.Level) string {
+	switch level {
+	case LevelNotice:
+		return "NOTICE"
+	case LevelCritical:
+		return "CRITICAL"
+	default:
+		return level.String()
+	}
+}
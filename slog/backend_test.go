@@ -0,0 +1,108 @@
+package slog
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"reflect"
+	"testing"
+
+	"github.com/tliron/commonlog"
+)
+
+func TestBackendName(t *testing.T) {
+	tests := []struct {
+		name        string
+		groupByName bool
+		expected    map[string]any
+	}{
+		{
+			name: "name key",
+			expected: map[string]any{
+				"level": "WARN",
+				"msg":   "hello",
+				"name":  "a.b",
+				"key":   "value",
+				"scope": "s",
+			},
+		},
+		{
+			name:        "group by name",
+			groupByName: true,
+			expected: map[string]any{
+				"level": "WARN",
+				"msg":   "hello",
+				"name":  "a.b",
+				"a.b": map[string]any{
+					"key":   "value",
+					"scope": "s",
+				},
+			},
+		},
+	}
+
+	defer slog.SetDefault(slog.Default())
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var buffer bytes.Buffer
+			handler := countingHandler{Handler: slog.NewJSONHandler(&buffer, &slog.HandlerOptions{
+				ReplaceAttr: func(groups []string, attr slog.Attr) slog.Attr {
+					if (len(groups) == 0) && (attr.Key == slog.TimeKey) {
+						return slog.Attr{}
+					}
+					return attr
+				},
+			})}
+
+			backend := NewBackend()
+			backend.Handler = &handler
+			backend.GroupByName = test.groupByName
+			backend.Configure(0, nil)
+
+			for range 2 {
+				buffer.Reset()
+
+				backend.NewMessage(commonlog.Warning, 0, "a", "b").
+					Set(commonlog.MESSAGE, "hello").
+					Set(commonlog.SCOPE, "s").
+					Set("key", "value").
+					Send()
+
+				var object map[string]any
+				if err := json.Unmarshal(buffer.Bytes(), &object); err != nil {
+					t.Fatalf("%s: %q", err, buffer.Bytes())
+				}
+				if !reflect.DeepEqual(object, test.expected) {
+					t.Errorf("object = %v, expected %v", object, test.expected)
+				}
+			}
+
+			if handler.derived != 0 {
+				t.Errorf("derived %d handlers, expected 0", handler.derived)
+			}
+		})
+	}
+}
+
+//
+// countingHandler
+//
+
+// Counts the handlers derived from it.
+type countingHandler struct {
+	slog.Handler
+	derived int
+}
+
+// ([slog.Handler] interface)
+func (self *countingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	self.derived++
+	return self.Handler.WithAttrs(attrs)
+}
+
+// ([slog.Handler] interface)
+func (self *countingHandler) WithGroup(name string) slog.Handler {
+	self.derived++
+	return self.Handler.WithGroup(name)
+}
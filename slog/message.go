@@ -3,6 +3,7 @@ package slog
 import (
 	contextpkg "context"
 	"log/slog"
+	"time"
 
 	"github.com/tliron/commonlog"
 	"github.com/tliron/go-kutil/util"
@@ -16,16 +17,27 @@ type Message struct {
 	logger  *slog.Logger
 	level   slog.Level
 	context contextpkg.Context
+	time    time.Time
+	pc      uintptr
 
-	message string
-	args    []any
+	scopeKey    string
+	nameKey     string
+	name        string
+	groupByName bool
+	message     string
+	args        []any
 }
 
-func NewMessage(logger *slog.Logger, level slog.Level, context contextpkg.Context) commonlog.Message {
+// The pc argument is the program counter of the logging location (used
+// for [slog.HandlerOptions] AddSource) and may be 0.
+func NewMessage(logger *slog.Logger, level slog.Level, context contextpkg.Context, time time.Time, pc uintptr) commonlog.Message {
 	return &Message{
-		logger:  logger,
-		level:   level,
-		context: context,
+		logger:   logger,
+		level:    level,
+		context:  context,
+		time:     time,
+		pc:       pc,
+		scopeKey: commonlog.SCOPE,
 	}
 }
 
@@ -35,6 +47,14 @@ func (self *Message) Set(key string, value any) commonlog.Message {
 	case commonlog.MESSAGE:
 		self.message = util.ToString(value)
 
+	case commonlog.TIME:
+		if time_, ok := commonlog.ToTime(value); ok {
+			self.time = time_
+		}
+
+	case commonlog.SCOPE:
+		self.args = append(self.args, self.scopeKey, value)
+
 	default:
 		self.args = append(self.args, key, value)
 	}
@@ -44,5 +64,20 @@ func (self *Message) Set(key string, value any) commonlog.Message {
 
 // ([commonlog.Message] interface)
 func (self *Message) Send() {
-	self.logger.Log(self.context, self.level, self.message, self.args...)
+	handler := self.logger.Handler()
+	if handler.Enabled(self.context, self.level) {
+		args := self.args
+		if self.name != "" {
+			if self.groupByName {
+				args = []any{slog.Group(self.name, args...)}
+			}
+			if self.nameKey != "" {
+				args = append([]any{self.nameKey, self.name}, args...)
+			}
+		}
+
+		record := slog.NewRecord(self.time, self.level, self.message, self.pc)
+		record.Add(args...)
+		handler.Handle(self.context, record)
+	}
 }
@@ -0,0 +1,45 @@
+package commonlog
+
+import (
+	"os"
+	"sync/atomic"
+)
+
+// Backends should write to these rather than directly to [os.Stdout] and
+// [os.Stderr], so that their output can be diverted when the process's own
+// stdout and stderr file descriptors are redirected (see the sink
+// package's StdioRedirect).
+var (
+	Stdout = NewStdioWriter(os.Stdout)
+	Stderr = NewStdioWriter(os.Stderr)
+)
+
+//
+// StdioWriter
+//
+
+// A thread-safe [io.Writer] that writes to a file that can be switched at
+// runtime.
+type StdioWriter struct {
+	file atomic.Pointer[os.File]
+}
+
+func NewStdioWriter(file *os.File) *StdioWriter {
+	var self StdioWriter
+	self.file.Store(file)
+	return &self
+}
+
+// ([io.Writer] interface)
+func (self *StdioWriter) Write(p []byte) (int, error) {
+	return self.file.Load().Write(p)
+}
+
+func (self *StdioWriter) GetFile() *os.File {
+	return self.file.Load()
+}
+
+// Returns the previous file.
+func (self *StdioWriter) SetFile(file *os.File) *os.File {
+	return self.file.Swap(file)
+}
@@ -0,0 +1,308 @@
+package commonlog
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	syslogNilValue = "-"
+	syslogBOM      = "\xEF\xBB\xBF"
+)
+
+//
+// SyslogMessage
+//
+
+// A parsed RFC 5424 or RFC 3164 syslog message.
+type SyslogMessage struct {
+	Facility       int
+	Severity       int
+	Time           time.Time
+	Hostname       string
+	AppName        string
+	ProcID         string
+	MsgID          string
+	StructuredData []SyslogStructuredDataElement
+	Message        string
+}
+
+type SyslogStructuredDataElement struct {
+	ID     string
+	Params []SyslogStructuredDataParam
+}
+
+type SyslogStructuredDataParam struct {
+	Name  string
+	Value string
+}
+
+// Parses an RFC 5424 or RFC 3164 syslog message (without transport
+// framing). RFC 3164 parsing is lenient, as the format was never strictly
+// adhered to in practice.
+func ParseSyslogMessage(text string) (*SyslogMessage, error) {
+	text = strings.TrimRight(text, "\r\n\x00")
+
+	if !strings.HasPrefix(text, "<") {
+		return nil, errors.New("syslog message does not start with priority")
+	}
+
+	end := strings.IndexByte(text, '>')
+	if (end < 2) || (end > 4) {
+		return nil, errors.New("malformed syslog priority")
+	}
+
+	priority, err := strconv.Atoi(text[1:end])
+	if (err != nil) || (priority < 0) || (priority > 191) {
+		return nil, fmt.Errorf("malformed syslog priority: %q", text[1:end])
+	}
+
+	self := SyslogMessage{
+		Facility: priority / 8,
+		Severity: priority % 8,
+	}
+
+	text = text[end+1:]
+	if strings.HasPrefix(text, "1 ") {
+		err = self.parseRFC5424(text[2:])
+	} else {
+		self.parseRFC3164(text)
+	}
+
+	if err == nil {
+		return &self, nil
+	} else {
+		return nil, err
+	}
+}
+
+// Maps the severity to a [Level]. Emergency and alert are mapped to
+// [Critical].
+func (self *SyslogMessage) Level() Level {
+	switch self.Severity {
+	case 0, 1, 2:
+		return Critical
+	case 3:
+		return Error
+	case 4:
+		return Warning
+	case 5:
+		return Notice
+	case 6:
+		return Info
+	default:
+		return Debug
+	}
+}
+
+// Creates a message on the logger with the mapped level (see
+// [SyslogMessage.Level]). Will return nil if the level is not loggable.
+//
+// Header fields are set as "hostname", "appName", "procID", "msgID",
+// and "facility" keys. Structured data params are set as "ID.name" keys,
+// except for those of elements with a "commonlog@" ID (as sent by the
+// CommonLog syslog backend), which are set using their names as is,
+// with "scope", "file", and "line" mapped to their special keys.
+//
+// Because syslog messages are received from external sources, control
+// characters in all strings, including keys, are escaped (see
+// [EscapeControlCharacters]).
+func (self *SyslogMessage) NewMessage(log Logger) Message {
+	if message := log.NewMessage(self.Level(), 1); message != nil {
+		if self.Message != "" {
+			message.Set(MESSAGE, EscapeControlCharacters(self.Message))
+		}
+
+		if !self.Time.IsZero() {
+			message.Set(TIME, self.Time)
+		}
+
+		if self.Hostname != "" {
+			message.Set("hostname", EscapeControlCharacters(self.Hostname))
+		}
+
+		if self.AppName != "" {
+			message.Set("appName", EscapeControlCharacters(self.AppName))
+		}
+
+		if self.ProcID != "" {
+			message.Set("procID", EscapeControlCharacters(self.ProcID))
+		}
+
+		if self.MsgID != "" {
+			message.Set("msgID", EscapeControlCharacters(self.MsgID))
+		}
+
+		message.Set("facility", self.Facility)
+
+		for _, element := range self.StructuredData {
+			if strings.HasPrefix(element.ID, "commonlog@") {
+				for _, param := range element.Params {
+					switch param.Name {
+					case "scope":
+						message.Set(SCOPE, EscapeControlCharacters(param.Value))
+					case "file":
+						message.Set(FILE, EscapeControlCharacters(param.Value))
+					case "line":
+						if line, err := strconv.ParseInt(param.Value, 10, 64); err == nil {
+							message.Set(LINE, line)
+						}
+					default:
+						message.Set(EscapeControlCharacters(param.Name), EscapeControlCharacters(param.Value))
+					}
+				}
+			} else {
+				for _, param := range element.Params {
+					message.Set(EscapeControlCharacters(element.ID+"."+param.Name), EscapeControlCharacters(param.Value))
+				}
+			}
+		}
+
+		return message
+	} else {
+		return nil
+	}
+}
+
+// <PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID STRUCTURED-DATA [MSG]
+func (self *SyslogMessage) parseRFC5424(text string) error {
+	var fields [5]string
+	for index := range fields {
+		var ok bool
+		if fields[index], text, ok = strings.Cut(text, " "); !ok {
+			return errors.New("truncated RFC 5424 syslog header")
+		}
+	}
+
+	if fields[0] != syslogNilValue {
+		if time_, err := time.Parse(time.RFC3339Nano, fields[0]); err == nil {
+			self.Time = time_
+		} else {
+			return err
+		}
+	}
+
+	self.Hostname = syslogField(fields[1])
+	self.AppName = syslogField(fields[2])
+	self.ProcID = syslogField(fields[3])
+	self.MsgID = syslogField(fields[4])
+
+	if strings.HasPrefix(text, syslogNilValue) {
+		text = text[1:]
+	} else {
+		var err error
+		if text, err = self.parseStructuredData(text); err != nil {
+			return err
+		}
+	}
+
+	if strings.HasPrefix(text, " ") {
+		self.Message = strings.TrimPrefix(text[1:], syslogBOM)
+	}
+
+	return nil
+}
+
+func (self *SyslogMessage) parseStructuredData(text string) (string, error) {
+	for strings.HasPrefix(text, "[") {
+		text = text[1:]
+
+		end := strings.IndexAny(text, " ]")
+		if end == -1 {
+			return "", errors.New("truncated syslog structured data")
+		}
+
+		element := SyslogStructuredDataElement{ID: text[:end]}
+		text = text[end:]
+
+		for strings.HasPrefix(text, " ") {
+			text = text[1:]
+
+			name, rest, ok := strings.Cut(text, `="`)
+			if !ok {
+				return "", errors.New("malformed syslog structured data param")
+			}
+
+			var value strings.Builder
+			escaped := false
+			closed := false
+			for index, r := range rest {
+				if escaped {
+					if (r != '"') && (r != '\\') && (r != ']') {
+						value.WriteRune('\\')
+					}
+					value.WriteRune(r)
+					escaped = false
+				} else if r == '\\' {
+					escaped = true
+				} else if r == '"' {
+					text = rest[index+1:]
+					closed = true
+					break
+				} else {
+					value.WriteRune(r)
+				}
+			}
+
+			if !closed {
+				return "", errors.New("truncated syslog structured data param")
+			}
+
+			element.Params = append(element.Params, SyslogStructuredDataParam{name, value.String()})
+		}
+
+		if !strings.HasPrefix(text, "]") {
+			return "", errors.New("malformed syslog structured data element")
+		}
+		text = text[1:]
+
+		self.StructuredData = append(self.StructuredData, element)
+	}
+
+	return text, nil
+}
+
+// <PRI>Mmm dd hh:mm:ss HOSTNAME TAG[PID]: MSG
+//
+// All parts after the priority are optional.
+func (self *SyslogMessage) parseRFC3164(text string) {
+	if len(text) >= len(time.Stamp) {
+		if time_, err := time.ParseInLocation(time.Stamp, text[:len(time.Stamp)], time.Local); err == nil {
+			// The year is not included, so we assume the current one
+			self.Time = time.Date(time.Now().Year(), time_.Month(), time_.Day(), time_.Hour(), time_.Minute(), time_.Second(), 0, time.Local)
+			text = strings.TrimPrefix(text[len(time.Stamp):], " ")
+		}
+	}
+
+	// The hostname is the first token unless that token is the tag
+	if token, rest, ok := strings.Cut(text, " "); ok && !strings.ContainsAny(token, ":[") {
+		if tag, _, _ := strings.Cut(rest, " "); strings.ContainsAny(tag, ":[") {
+			self.Hostname = token
+			text = rest
+		}
+	}
+
+	if tag, rest, ok := strings.Cut(text, ": "); ok && !strings.Contains(tag, " ") {
+		if name, pid, ok := strings.Cut(tag, "["); ok {
+			self.AppName = name
+			self.ProcID = strings.TrimSuffix(pid, "]")
+		} else {
+			self.AppName = tag
+		}
+		text = rest
+	}
+
+	self.Message = text
+}
+
+// Utils
+
+func syslogField(field string) string {
+	if field == syslogNilValue {
+		return ""
+	}
+	return field
+}
@@ -0,0 +1,73 @@
+package commonlog_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/tliron/commonlog"
+	"github.com/tliron/commonlog/internal/recording"
+)
+
+func TestSyslogMessageEscaping(t *testing.T) {
+	backend := recording.Use(t)
+
+	text := "<14>1 2024-01-02T03:04:05Z host\x1b app\x1b 1\x1b id\x1b " +
+		"[commonlog@1 scope=\"s\nforged\" file=\"f\nforged\" k\x1b=\"v\nforged\"]" +
+		"[ex@1 p=\"q\nforged\"] hello\nNOTICE forged"
+
+	syslogMessage, err := commonlog.ParseSyslogMessage(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	syslogMessage.NewMessage(commonlog.GetLogger("syslog")).Send()
+
+	messages := backend.GetMessages()
+	if len(messages) != 1 {
+		t.Fatalf("got %d messages, expected 1", len(messages))
+	}
+
+	message := messages[0]
+	if text := message.Message.Message; text != `hello\nNOTICE forged` {
+		t.Errorf("message = %q", text)
+	}
+	if scope := message.Message.Scope; scope != `s\nforged` {
+		t.Errorf("scope = %q", scope)
+	}
+	if file := message.Message.File; file != `f\nforged` {
+		t.Errorf("file = %q", file)
+	}
+
+	values := message.GetValues()
+	expected := map[string]any{
+		"hostname": `host\x1b`,
+		"appName":  `app\x1b`,
+		"procID":   `1\x1b`,
+		"msgID":    `id\x1b`,
+		"facility": 1,
+		`k\x1b`:    `v\nforged`,
+		"ex@1.p":   `q\nforged`,
+	}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("values = %#v, expected %#v", values, expected)
+	}
+}
+
+func TestSyslogLineParserEscaping(t *testing.T) {
+	backend := recording.Use(t)
+
+	parse := commonlog.SyslogLineParser(commonlog.GetLogger("syslog"), commonlog.Info)
+	parse("<14>1 - host\x1b - - - - hello\nNOTICE forged").Send()
+
+	messages := backend.GetMessages()
+	if len(messages) != 1 {
+		t.Fatalf("got %d messages, expected 1", len(messages))
+	}
+
+	message := messages[0]
+	if text := message.Message.Message; text != `hello\nNOTICE forged` {
+		t.Errorf("message = %q", text)
+	}
+	if hostname := message.Get("hostname"); hostname != `host\x1b` {
+		t.Errorf("hostname = %#v", hostname)
+	}
+}
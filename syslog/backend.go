@@ -0,0 +1,167 @@
+package syslog
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/tliron/commonlog"
+	"github.com/tliron/go-kutil/util"
+)
+
+func init() {
+	backend := NewBackend()
+	backend.Configure(0, nil)
+	commonlog.SetBackend(backend)
+}
+
+//
+// Backend
+//
+
+type Backend struct {
+	Format           Format
+	Facility         Facility
+	Hostname         string
+	AppName          string
+	ProcID           string
+	StructuredDataID string
+
+	// Used for new transports created by [Backend.Configure].
+	Framing Framing
+
+	transport     *Transport
+	writer        io.Writer
+	nameHierarchy *commonlog.NameHierarchy
+}
+
+func NewBackend() *Backend {
+	hostname, _ := os.Hostname()
+	return &Backend{
+		Format:           RFC5424,
+		Facility:         FacilityUser,
+		Hostname:         hostname,
+		AppName:          filepath.Base(os.Args[0]),
+		ProcID:           strconv.Itoa(os.Getpid()),
+		StructuredDataID: DefaultStructuredDataID,
+		nameHierarchy:    commonlog.NewNameHierarchy(),
+	}
+}
+
+// The path is a URL (see [NewTransportFromURL]). If nil will log to the
+// local syslog unix socket.
+//
+// ([commonlog.Backend] interface)
+func (self *Backend) Configure(verbosity int, path *string) {
+	maxLevel := commonlog.VerbosityToMaxLevel(verbosity)
+
+	if self.transport != nil {
+		self.transport.Close()
+		self.transport = nil
+	}
+
+	if maxLevel == commonlog.None {
+		self.writer = io.Discard
+		self.nameHierarchy.SetMaxLevel(commonlog.None)
+	} else {
+		if path != nil {
+			if transport, err := NewTransportFromURL(*path); err == nil {
+				transport.Framing = self.Framing
+				self.SetTransport(transport)
+			} else {
+				util.Failf("syslog error: %s", err.Error())
+			}
+		} else {
+			transport := NewTransport("", "")
+			transport.Framing = self.Framing
+			self.SetTransport(transport)
+		}
+
+		self.nameHierarchy.SetMaxLevel(maxLevel)
+	}
+}
+
+// ([commonlog.Backend] interface)
+func (self *Backend) GetWriter() io.Writer {
+	return self.writer
+}
+
+// ([commonlog.Backend] interface)
+func (self *Backend) NewMessage(level commonlog.Level, depth int, name ...string) commonlog.Message {
+	if (self.transport != nil) && self.AllowLevel(level, name...) {
+		message := self.newLinearMessage(LevelToSeverity(level), name)
+		return commonlog.TraceMessage(message, depth)
+	} else {
+		return nil
+	}
+}
+
+// ([commonlog.Backend] interface)
+func (self *Backend) AllowLevel(level commonlog.Level, name ...string) bool {
+	return self.nameHierarchy.AllowLevel(level, name...)
+}
+
+// ([commonlog.Backend] interface)
+func (self *Backend) SetMaxLevel(level commonlog.Level, name ...string) {
+	self.nameHierarchy.SetMaxLevel(level, name...)
+}
+
+// ([commonlog.Backend] interface)
+func (self *Backend) GetMaxLevel(name ...string) commonlog.Level {
+	return self.nameHierarchy.GetMaxLevel(name...)
+}
+
+// Replaces the transport, e.g. in order to use a [Transport.TLSConfig].
+// The previous transport, if any, is closed.
+func (self *Backend) SetTransport(transport *Transport) {
+	if (self.transport != nil) && (self.transport != transport) {
+		self.transport.Close()
+	}
+
+	self.transport = transport
+	self.writer = SyslogWriter{self}
+}
+
+func (self *Backend) newLinearMessage(severity Severity, name []string) *commonlog.LinearMessage {
+	message := commonlog.NewLinearMessage(func(message *commonlog.LinearMessage) {
+		self.send(severity, name, message)
+	})
+
+	message.Time = time.Now()
+
+	if self.transport.EscapeNewlines() {
+		message.Multiline = commonlog.MultilineEscape
+	} else {
+		message.Multiline = commonlog.MultilineRaw
+	}
+
+	return message
+}
+
+func (self *Backend) send(severity Severity, name []string, message *commonlog.LinearMessage) {
+	header := Header{
+		Facility:         self.Facility,
+		Severity:         severity,
+		Hostname:         self.Hostname,
+		AppName:          self.AppName,
+		ProcID:           self.ProcID,
+		StructuredDataID: self.StructuredDataID,
+		Name:             name,
+	}
+
+	var message_ string
+	switch self.Format {
+	case RFC3164:
+		message_ = FormatRFC3164(&header, message)
+	default:
+		message_ = FormatRFC5424(&header, message)
+	}
+
+	if err := self.transport.Send(message_); err != nil {
+		// Better than losing the message
+		fmt.Fprintf(commonlog.Stderr, "syslog error: %s\n%s\n", err.Error(), message_)
+	}
+}
@@ -0,0 +1,72 @@
+package syslog
+
+import (
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tliron/commonlog"
+)
+
+func TestBackend(t *testing.T) {
+	for _, format := range []Format{RFC5424, RFC3164} {
+		t.Run(format.String(), func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "socket")
+			conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+
+			backend := NewBackend()
+			backend.Format = format
+			backend.Hostname = "host"
+			backend.AppName = "app"
+			url := "unixgram://" + path
+			backend.Configure(0, &url)
+			defer backend.Configure(-4, nil)
+
+			if message := backend.NewMessage(commonlog.Info, 0, "a", "b"); message != nil {
+				t.Error("expected Info to be filtered out at verbosity 0")
+			}
+
+			backend.NewMessage(commonlog.Warning, 0, "a", "b").
+				Set(commonlog.MESSAGE, "hello").
+				Set("key", "value").
+				Send()
+
+			buffer := make([]byte, 1024)
+			conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+			count, err := conn.Read(buffer)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			message, err := commonlog.ParseSyslogMessage(string(buffer[:count]))
+			if err != nil {
+				t.Fatalf("%s: %q", err, buffer[:count])
+			}
+
+			if message.Severity != int(SeverityWarning) {
+				t.Errorf("severity = %d, expected %d", message.Severity, SeverityWarning)
+			}
+			if message.Facility != int(FacilityUser) {
+				t.Errorf("facility = %d, expected %d", message.Facility, FacilityUser)
+			}
+			if message.Hostname != "host" {
+				t.Errorf("hostname = %q", message.Hostname)
+			}
+			if message.AppName != "app" {
+				t.Errorf("app name = %q", message.AppName)
+			}
+			if message.Time.IsZero() {
+				t.Error("time not set")
+			}
+			if !strings.Contains(message.Message, "hello") {
+				t.Errorf("message = %q", message.Message)
+			}
+		})
+	}
+}
@@ -0,0 +1,319 @@
+package syslog
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tliron/commonlog"
+)
+
+const (
+	// Default SD-ID for the structured data element carrying message keys.
+	// The enterprise number is the one reserved for documentation in
+	// RFC 5612.
+	DefaultStructuredDataID = "commonlog@32473"
+
+	NILVALUE = "-"
+
+	RFC5424TimeFormat = "2006-01-02T15:04:05.000000Z07:00"
+	RFC3164TimeFormat = time.Stamp
+)
+
+//
+// Format
+//
+
+type Format int
+
+const (
+	RFC5424 Format = 0
+	RFC3164 Format = 1
+)
+
+// ([fmt.Stringify] interface)
+func (self Format) String() string {
+	switch self {
+	case RFC5424:
+		return "RFC5424"
+	case RFC3164:
+		return "RFC3164"
+	default:
+		return ""
+	}
+}
+
+//
+// Facility
+//
+
+type Facility int
+
+const (
+	FacilityKern     Facility = 0
+	FacilityUser     Facility = 1
+	FacilityMail     Facility = 2
+	FacilityDaemon   Facility = 3
+	FacilityAuth     Facility = 4
+	FacilitySyslog   Facility = 5
+	FacilityLPR      Facility = 6
+	FacilityNews     Facility = 7
+	FacilityUUCP     Facility = 8
+	FacilityCron     Facility = 9
+	FacilityAuthPriv Facility = 10
+	FacilityFTP      Facility = 11
+	FacilityLocal0   Facility = 16
+	FacilityLocal1   Facility = 17
+	FacilityLocal2   Facility = 18
+	FacilityLocal3   Facility = 19
+	FacilityLocal4   Facility = 20
+	FacilityLocal5   Facility = 21
+	FacilityLocal6   Facility = 22
+	FacilityLocal7   Facility = 23
+)
+
+//
+// Severity
+//
+
+type Severity int
+
+const (
+	SeverityEmergency     Severity = 0
+	SeverityAlert         Severity = 1
+	SeverityCritical      Severity = 2
+	SeverityError         Severity = 3
+	SeverityWarning       Severity = 4
+	SeverityNotice        Severity = 5
+	SeverityInformational Severity = 6
+	SeverityDebug         Severity = 7
+)
+
+func LevelToSeverity(level commonlog.Level) Severity {
+	switch level {
+	case commonlog.Critical:
+		return SeverityCritical
+	case commonlog.Error:
+		return SeverityError
+	case commonlog.Warning:
+		return SeverityWarning
+	case commonlog.Notice:
+		return SeverityNotice
+	case commonlog.Info:
+		return SeverityInformational
+	case commonlog.Debug:
+		return SeverityDebug
+	default:
+		panic(fmt.Sprintf("unsupported log level: %d", level))
+	}
+}
+
+// [SeverityEmergency] and [SeverityAlert] are mapped to [commonlog.Critical].
+func SeverityToLevel(severity Severity) commonlog.Level {
+	switch severity {
+	case SeverityEmergency, SeverityAlert, SeverityCritical:
+		return commonlog.Critical
+	case SeverityError:
+		return commonlog.Error
+	case SeverityWarning:
+		return commonlog.Warning
+	case SeverityNotice:
+		return commonlog.Notice
+	case SeverityInformational:
+		return commonlog.Info
+	case SeverityDebug:
+		return commonlog.Debug
+	default:
+		panic(fmt.Sprintf("unsupported syslog severity: %d", severity))
+	}
+}
+
+func Priority(facility Facility, severity Severity) int {
+	return int(facility)*8 + int(severity)
+}
+
+//
+// Header
+//
+
+// Message metadata that is not part of the [commonlog.LinearMessage].
+type Header struct {
+	Facility         Facility
+	Severity         Severity
+	Hostname         string
+	AppName          string
+	ProcID           string
+	StructuredDataID string
+	Name             []string
+}
+
+// Formats the message according to RFC 5424. Message keys, including the
+// name, scope, and location, are carried in a single structured data
+// element.
+//
+// See: https://datatracker.ietf.org/doc/html/rfc5424
+func FormatRFC5424(header *Header, message *commonlog.LinearMessage) string {
+	var builder strings.Builder
+
+	builder.WriteRune('<')
+	builder.WriteString(strconv.Itoa(Priority(header.Facility, header.Severity)))
+	builder.WriteString(">1 ")
+
+	if message.Time.IsZero() {
+		builder.WriteString(NILVALUE)
+	} else {
+		builder.WriteString(message.Time.Format(RFC5424TimeFormat))
+	}
+
+	builder.WriteRune(' ')
+	builder.WriteString(headerField(header.Hostname, 255))
+	builder.WriteRune(' ')
+	builder.WriteString(headerField(header.AppName, 48))
+	builder.WriteRune(' ')
+	builder.WriteString(headerField(header.ProcID, 128))
+	builder.WriteString(" - ") // MSGID
+
+	if sd := structuredData(header, message); sd != "" {
+		builder.WriteString(sd)
+	} else {
+		builder.WriteString(NILVALUE)
+	}
+
+	if message.Message != "" {
+		builder.WriteRune(' ')
+		builder.WriteString(message.Multiline.Render(message.Message))
+	}
+
+	return builder.String()
+}
+
+// Formats the message according to RFC 3164. The name and scope are
+// prefixed to the text and message keys are appended to it.
+//
+// See: https://datatracker.ietf.org/doc/html/rfc3164
+func FormatRFC3164(header *Header, message *commonlog.LinearMessage) string {
+	var builder strings.Builder
+
+	builder.WriteRune('<')
+	builder.WriteString(strconv.Itoa(Priority(header.Facility, header.Severity)))
+	builder.WriteRune('>')
+
+	time_ := message.Time
+	if time_.IsZero() {
+		time_ = time.Now()
+	}
+	builder.WriteString(time_.Format(RFC3164TimeFormat))
+
+	if header.Hostname != "" {
+		builder.WriteRune(' ')
+		builder.WriteString(headerField(header.Hostname, 255))
+	}
+
+	builder.WriteRune(' ')
+	builder.WriteString(headerField(header.AppName, 32))
+	if header.ProcID != "" {
+		builder.WriteRune('[')
+		builder.WriteString(header.ProcID)
+		builder.WriteRune(']')
+	}
+	builder.WriteString(": ")
+
+	builder.WriteString(message.StringWithPrefix(header.Name...))
+
+	return builder.String()
+}
+
+// Utils
+
+func structuredData(header *Header, message *commonlog.LinearMessage) string {
+	var params strings.Builder
+
+	writeParam := func(key string, value string) {
+		params.WriteRune(' ')
+		params.WriteString(sdName(key))
+		params.WriteString(`="`)
+		params.WriteString(sdParamValue(value))
+		params.WriteRune('"')
+	}
+
+	if len(header.Name) > 0 {
+		writeParam("name", strings.Join(header.Name, "."))
+	}
+
+	if message.Scope != "" {
+		writeParam("scope", message.Scope)
+	}
+
+	values := message.FlatValues()
+	for index := range values {
+		value := &values[index]
+		writeParam(value.Key, value.String())
+	}
+
+	if message.File != "" {
+		writeParam("file", message.File)
+		if message.Line != -1 {
+			writeParam("line", strconv.FormatInt(message.Line, 10))
+		}
+	}
+
+	if params.Len() == 0 {
+		return ""
+	}
+
+	id := header.StructuredDataID
+	if id == "" {
+		id = DefaultStructuredDataID
+	}
+
+	return "[" + sdName(id) + params.String() + "]"
+}
+
+// Header fields must be printable US-ASCII without spaces.
+func headerField(value string, maxLength int) string {
+	if value == "" {
+		return NILVALUE
+	}
+
+	value = strings.Map(func(r rune) rune {
+		if (r < 33) || (r > 126) {
+			return '_'
+		}
+		return r
+	}, value)
+
+	if len(value) > maxLength {
+		value = value[:maxLength]
+	}
+
+	return value
+}
+
+// SD-NAME is printable US-ASCII except '=', ' ', ']', and '"', up to 32
+// characters.
+func sdName(name string) string {
+	name = strings.TrimLeft(name, "_")
+	if name == "" {
+		return "_"
+	}
+
+	name = strings.Map(func(r rune) rune {
+		if (r < 33) || (r > 126) || (r == '=') || (r == ']') || (r == '"') {
+			return '_'
+		}
+		return r
+	}, name)
+
+	if len(name) > 32 {
+		name = name[:32]
+	}
+
+	return name
+}
+
+var sdParamValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
+
+func sdParamValue(value string) string {
+	return sdParamValueReplacer.Replace(value)
+}
@@ -0,0 +1,248 @@
+package syslog
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultPort    = 514
+	DefaultTLSPort = 6514
+
+	DefaultDialTimeout = 10 * time.Second
+)
+
+// Unix socket paths tried, in order, for local syslog.
+var LocalPaths = []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+
+//
+// Framing
+//
+
+// Framing for stream transports (TCP, TLS, and unix stream sockets).
+// Datagram transports always send one message per datagram.
+//
+// See: https://datatracker.ietf.org/doc/html/rfc6587
+type Framing int
+
+const (
+	// [OctetCounting] for TCP and TLS, [NewlineFraming] for unix stream
+	// sockets (including the local syslog socket), because local syslog
+	// daemons expect terminated messages.
+	DefaultFraming Framing = 0
+
+	// Prefixes each message with its length in bytes.
+	OctetCounting Framing = 1
+
+	// Terminates each message with a newline. Newlines within the message
+	// are escaped.
+	NewlineFraming Framing = 2
+)
+
+//
+// Transport
+//
+
+// A connection to a syslog collector. It is established when the first
+// message is sent and reestablished once if a write fails.
+type Transport struct {
+	// "udp", "tcp", "tls", "unix", "unixgram", or empty for the local
+	// syslog unix socket (see [LocalPaths]).
+	Network string
+
+	Address     string
+	TLSConfig   *tls.Config
+	Framing     Framing
+	DialTimeout time.Duration
+
+	conn   net.Conn
+	stream bool
+	probed bool
+	lock   sync.Mutex
+}
+
+func NewTransport(network string, address string) *Transport {
+	return &Transport{
+		Network:     network,
+		Address:     address,
+		DialTimeout: DefaultDialTimeout,
+	}
+}
+
+// Parses a URL in the form "udp://host:port", "tcp://host:port",
+// "tls://host:port", "unix:///path", or "unixgram:///path". The port
+// defaults to [DefaultPort] or [DefaultTLSPort].
+func NewTransportFromURL(url_ string) (*Transport, error) {
+	if url__, err := url.Parse(url_); err == nil {
+		switch url__.Scheme {
+		case "udp", "tcp", "tls":
+			host := url__.Hostname()
+			port := url__.Port()
+			if port == "" {
+				if url__.Scheme == "tls" {
+					port = strconv.Itoa(DefaultTLSPort)
+				} else {
+					port = strconv.Itoa(DefaultPort)
+				}
+			}
+			return NewTransport(url__.Scheme, net.JoinHostPort(host, port)), nil
+
+		case "unix", "unixgram":
+			return NewTransport(url__.Scheme, url__.Path), nil
+
+		default:
+			return nil, fmt.Errorf("unsupported syslog URL scheme: %s", url__.Scheme)
+		}
+	} else {
+		return nil, err
+	}
+}
+
+// Returns true if newlines in messages must be escaped.
+//
+// For the local syslog socket this will connect once in order to determine
+// whether it is a stream socket. The result, including a failure to
+// connect, is remembered until the next connection attempt in
+// [Transport.Send]. Either way, [NewlineFraming] guarantees that newlines
+// are escaped when sending.
+func (self *Transport) EscapeNewlines() bool {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	switch self.Network {
+	case "tcp", "tls", "unix":
+		return self.getFraming() == NewlineFraming
+
+	case "":
+		if !self.probed {
+			// On failure we will try again in Send
+			self.connect()
+		}
+		return self.stream && (self.getFraming() == NewlineFraming)
+
+	default:
+		return false
+	}
+}
+
+// Sends a single message, connecting if necessary.
+func (self *Transport) Send(message string) error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	var err error
+	for range 2 {
+		if self.conn == nil {
+			if err = self.connect(); err != nil {
+				return err
+			}
+		}
+
+		if err = self.write(message); err == nil {
+			return nil
+		}
+
+		// Try again with a new connection
+		self.close()
+	}
+
+	return err
+}
+
+// ([io.Closer] interface)
+func (self *Transport) Close() error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	return self.close()
+}
+
+func (self *Transport) connect() error {
+	timeout := self.DialTimeout
+	if timeout == 0 {
+		timeout = DefaultDialTimeout
+	}
+
+	switch self.Network {
+	case "":
+		self.probed = true
+		self.stream = false
+		for _, network := range []string{"unixgram", "unix"} {
+			for _, path := range LocalPaths {
+				if conn, err := net.DialTimeout(network, path, timeout); err == nil {
+					self.conn = conn
+					self.stream = network == "unix"
+					return nil
+				}
+			}
+		}
+		return errors.New("local syslog socket not found")
+
+	case "tls":
+		dialer := net.Dialer{Timeout: timeout}
+		if conn, err := tls.DialWithDialer(&dialer, "tcp", self.Address, self.TLSConfig); err == nil {
+			self.conn = conn
+			self.stream = true
+			return nil
+		} else {
+			return err
+		}
+
+	case "udp", "tcp", "unix", "unixgram":
+		if conn, err := net.DialTimeout(self.Network, self.Address, timeout); err == nil {
+			self.conn = conn
+			self.stream = (self.Network == "tcp") || (self.Network == "unix")
+			return nil
+		} else {
+			return err
+		}
+
+	default:
+		return fmt.Errorf("unsupported syslog network: %s", self.Network)
+	}
+}
+
+func (self *Transport) write(message string) error {
+	if self.stream {
+		switch self.getFraming() {
+		case NewlineFraming:
+			message = strings.ReplaceAll(message, "\n", `\n`) + "\n"
+		default:
+			message = strconv.Itoa(len(message)) + " " + message
+		}
+	}
+
+	_, err := io.WriteString(self.conn, message)
+	return err
+}
+
+// Call with lock.
+func (self *Transport) getFraming() Framing {
+	if self.Framing != DefaultFraming {
+		return self.Framing
+	}
+
+	switch self.Network {
+	case "tcp", "tls":
+		return OctetCounting
+	default:
+		return NewlineFraming
+	}
+}
+
+func (self *Transport) close() error {
+	if self.conn != nil {
+		err := self.conn.Close()
+		self.conn = nil
+		return err
+	}
+	return nil
+}
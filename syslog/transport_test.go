@@ -0,0 +1,183 @@
+package syslog
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTransportFraming(t *testing.T) {
+	tests := []struct {
+		name           string
+		network        string
+		framing        Framing
+		escapeNewlines bool
+		expected       string
+	}{
+		{"tcp default", "tcp", DefaultFraming, false, "5 hello"},
+		{"tcp octet counting", "tcp", OctetCounting, false, "5 hello"},
+		{"tcp newline", "tcp", NewlineFraming, true, "hello\n"},
+		{"unix default", "unix", DefaultFraming, true, "hello\n"},
+		{"unix octet counting", "unix", OctetCounting, false, "5 hello"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			address := filepath.Join(t.TempDir(), "socket")
+			if test.network == "tcp" {
+				address = "127.0.0.1:0"
+			}
+
+			listener, err := net.Listen(test.network, address)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer listener.Close()
+
+			transport := NewTransport(test.network, listener.Addr().String())
+			transport.Framing = test.framing
+			defer transport.Close()
+
+			if escapeNewlines := transport.EscapeNewlines(); escapeNewlines != test.escapeNewlines {
+				t.Errorf("EscapeNewlines() = %t, expected %t", escapeNewlines, test.escapeNewlines)
+			}
+
+			if err := transport.Send("hello"); err != nil {
+				t.Fatal(err)
+			}
+
+			conn, err := listener.Accept()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+
+			buffer := make([]byte, len(test.expected))
+			conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+			if _, err := io.ReadFull(bufio.NewReader(conn), buffer); err != nil {
+				t.Fatal(err)
+			}
+			if received := string(buffer); received != test.expected {
+				t.Errorf("received %q, expected %q", received, test.expected)
+			}
+		})
+	}
+}
+
+func TestTransportDatagram(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "socket")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	transport, err := NewTransportFromURL("unixgram://" + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer transport.Close()
+
+	if transport.EscapeNewlines() {
+		t.Error("EscapeNewlines() = true, expected false")
+	}
+
+	for _, message := range []string{"first", "second\nline"} {
+		if err := transport.Send(message); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	buffer := make([]byte, 1024)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for _, expected := range []string{"first", "second\nline"} {
+		count, err := conn.Read(buffer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if received := string(buffer[:count]); received != expected {
+			t.Errorf("received %q, expected %q", received, expected)
+		}
+	}
+}
+
+func TestTransportLocal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "socket")
+	previous := LocalPaths
+	LocalPaths = []string{path}
+	defer func() {
+		LocalPaths = previous
+	}()
+
+	transport := NewTransport("", "")
+	defer transport.Close()
+
+	// The failure to connect is remembered
+	if transport.EscapeNewlines() {
+		t.Error("EscapeNewlines() = true, expected false")
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	if transport.EscapeNewlines() {
+		t.Error("EscapeNewlines() = true, expected false until the next Send")
+	}
+
+	// Newlines are escaped even though the message was not
+	if err := transport.Send("hello\nworld"); err != nil {
+		t.Fatal(err)
+	}
+
+	if !transport.EscapeNewlines() {
+		t.Error("EscapeNewlines() = false, expected true")
+	}
+
+	conn, err := listener.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if received, err := bufio.NewReader(conn).ReadString('\n'); err != nil {
+		t.Fatal(err)
+	} else if expected := "hello\\nworld\n"; received != expected {
+		t.Errorf("received %q, expected %q", received, expected)
+	}
+}
+
+func TestNewTransportFromURL(t *testing.T) {
+	tests := []struct {
+		url     string
+		network string
+		address string
+	}{
+		{"udp://localhost", "udp", "localhost:514"},
+		{"tcp://localhost:1514", "tcp", "localhost:1514"},
+		{"tls://localhost", "tls", "localhost:6514"},
+		{"unix:///dev/log", "unix", "/dev/log"},
+		{"unixgram:///dev/log", "unixgram", "/dev/log"},
+	}
+
+	for _, test := range tests {
+		transport, err := NewTransportFromURL(test.url)
+		if err != nil {
+			t.Errorf("%s: %s", test.url, err)
+			continue
+		}
+		if (transport.Network != test.network) || (transport.Address != test.address) {
+			t.Errorf("%s: got %s %s, expected %s %s", test.url, transport.Network, transport.Address, test.network, test.address)
+		}
+	}
+
+	if _, err := NewTransportFromURL("http://localhost"); err == nil {
+		t.Error("expected an error for an unsupported scheme")
+	}
+}
@@ -0,0 +1,24 @@
+package syslog
+
+import (
+	"strings"
+
+	"github.com/tliron/go-kutil/util"
+)
+
+//
+// SyslogWriter
+//
+
+// Sends each write as a message with [SeverityInformational].
+type SyslogWriter struct {
+	backend *Backend
+}
+
+// ([io.Writer] interface)
+func (self SyslogWriter) Write(p []byte) (int, error) {
+	message := self.backend.newLinearMessage(SeverityInformational, nil)
+	message.Message = strings.TrimRight(util.BytesToString(p), "\n")
+	message.Send()
+	return len(p), nil
+}
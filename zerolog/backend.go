@@ -5,6 +5,7 @@ import (
 	"io"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/rs/zerolog"
 	logpkg "github.com/rs/zerolog/log"
@@ -17,6 +18,8 @@ const (
 	LogFileWritePermissions = 0600
 	DefaultBufferSize       = 1_000
 	TimeFormat              = "2006/01/02 15:04:05.000"
+	NameFieldName           = "name"
+	ScopeFieldName          = "scope"
 )
 
 func init() {
@@ -33,11 +36,25 @@ func init() {
 // high performance and low resource use due to aggressively avoiding allocations. If you need
 // that optimization then you should use zerolog's API directly.
 
+// Messages are sent with their "_message" key as zerolog's message, their
+// "_file" and "_line" keys as zerolog's caller, and their "_scope" key as
+// a "scope" field. [commonlog.Critical] is sent at [zerolog.FatalLevel]
+// (without exiting).
+//
+// The max level is handled by us: zerolog's global level (see
+// [zerolog.SetGlobalLevel]) is left as is for direct users of zerolog and
+// does not filter our messages.
+//
+// When logging to stderr the default is to use zerolog's ConsoleWriter.
+// Set JSON to true to write raw JSON lines instead, e.g. for container
+// environments that collect stderr.
 type Backend struct {
 	Writer     io.Writer
 	BufferSize int
 	Buffered   bool
+	JSON       bool
 
+	logger        zerolog.Logger
 	nameHierarchy *commonlog.NameHierarchy
 }
 
@@ -56,8 +73,8 @@ func (self *Backend) Configure(verbosity int, path *string) {
 	if maxLevel == commonlog.None {
 		self.Writer = io.Discard
 		self.nameHierarchy.SetMaxLevel(commonlog.None)
-		logpkg.Logger = zerolog.New(self.Writer)
-		zerolog.SetGlobalLevel(zerolog.Disabled)
+		self.logger = zerolog.New(self.Writer).Level(zerolog.Disabled)
+		logpkg.Logger = self.logger
 	} else {
 		if path != nil {
 			if file, err := os.OpenFile(*path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, LogFileWritePermissions); err == nil {
@@ -69,24 +86,33 @@ func (self *Backend) Configure(verbosity int, path *string) {
 				} else {
 					self.Writer = util.NewSyncedWriter(file)
 				}
-				logpkg.Logger = zerolog.New(self.Writer)
+				self.logger = zerolog.New(self.Writer)
 			} else {
 				util.Failf("log file error: %s", err.Error())
 			}
+		} else if self.JSON {
+			if self.Buffered {
+				writer := util.NewBufferedWriter(commonlog.Stderr, self.BufferSize, false)
+				util.OnExitError(writer.Close)
+				self.Writer = writer
+			} else {
+				self.Writer = util.NewSyncedWriter(commonlog.Stderr)
+			}
+			self.logger = zerolog.New(self.Writer)
 		} else {
-			self.Writer = os.Stderr
+			self.Writer = commonlog.Stderr
 			if terminal.ColorizeStderr {
-				// Note: ConsoleWriter has its own built-in support for
-				// colorization, including for Windows terminals, which
-				// relies on Out being equal to Stdout or Stderr, thus
-				// we shouldn't use any wrappers for Out such as
-				// BufferedWriter or SyncedWriter
-				logpkg.Logger = zerolog.New(zerolog.ConsoleWriter{
+				// Note: ConsoleWriter would wrap Out for colorization on
+				// Windows only if it were os.Stderr, but terminal has
+				// already enabled ANSI support when ColorizeStderr is true
+				// (and we must use commonlog.Stderr in order to support
+				// redirection)
+				self.logger = zerolog.New(zerolog.ConsoleWriter{
 					Out:        self.Writer,
 					TimeFormat: TimeFormat,
 				})
 			} else {
-				logpkg.Logger = zerolog.New(zerolog.ConsoleWriter{
+				self.logger = zerolog.New(zerolog.ConsoleWriter{
 					Out:        self.Writer,
 					TimeFormat: TimeFormat,
 					NoColor:    true,
@@ -95,7 +121,10 @@ func (self *Backend) Configure(verbosity int, path *string) {
 		}
 
 		zerolog.TimeFieldFormat = zerolog.TimeFormatUnixMicro
-		logpkg.Logger = logpkg.With().Timestamp().Logger()
+
+		// Note: our messages add their own timestamp (see Message.Send), so
+		// only the global logger used directly with zerolog's API gets the hook
+		logpkg.Logger = self.logger.With().Timestamp().Logger()
 
 		self.nameHierarchy.SetMaxLevel(maxLevel)
 	}
@@ -109,31 +138,40 @@ func (self *Backend) GetWriter() io.Writer {
 // ([commonlog.Backend] interface)
 func (self *Backend) NewMessage(level commonlog.Level, depth int, name ...string) commonlog.Message {
 	if self.AllowLevel(level, name...) {
-		context := logpkg.With()
+		context := self.logger.With()
 		if name := strings.Join(name, "."); len(name) > 0 {
-			context = context.Str("name", name)
+			context = context.Str(NameFieldName, name)
 		}
 		logger := context.Logger()
 
-		var event *zerolog.Event
+		var zerologLevel zerolog.Level
 		switch level {
 		case commonlog.Critical:
-			event = logger.Error()
+			// Note: unlike logger.Fatal this will not exit
+			zerologLevel = zerolog.FatalLevel
 		case commonlog.Error:
-			event = logger.Error()
+			zerologLevel = zerolog.ErrorLevel
 		case commonlog.Warning:
-			event = logger.Warn()
+			zerologLevel = zerolog.WarnLevel
 		case commonlog.Notice:
-			event = logger.Info()
+			zerologLevel = zerolog.InfoLevel
 		case commonlog.Info:
-			event = logger.Debug()
+			zerologLevel = zerolog.DebugLevel
 		case commonlog.Debug:
-			event = logger.Trace()
+			zerologLevel = zerolog.TraceLevel
 		default:
 			panic(fmt.Sprintf("unsupported log level: %d", level))
 		}
 
-		return commonlog.TraceMessage(NewMessage(event), depth)
+		// We are handling the max level ourselves, so we are not using
+		// logger.WithLevel, which would be filtered by zerolog's global level
+		// (by default it filters out trace)
+		event := logger.Log()
+		if zerolog.LevelFieldName != "" {
+			event.Str(zerolog.LevelFieldName, zerolog.LevelFieldMarshalFunc(zerologLevel))
+		}
+
+		return commonlog.TraceMessage(NewMessage(event, time.Now()), depth)
 	} else {
 		return nil
 	}
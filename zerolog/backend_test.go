@@ -0,0 +1,60 @@
+package zerolog
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/tliron/commonlog"
+)
+
+func TestBackendLevels(t *testing.T) {
+	globalLevel := zerolog.GlobalLevel()
+
+	backend := NewBackend()
+	backend.JSON = true
+	backend.Buffered = false
+	backend.Configure(2, nil)
+	defer backend.Configure(0, nil)
+
+	if level := zerolog.GlobalLevel(); level != globalLevel {
+		t.Errorf("global level = %s, expected %s", level, globalLevel)
+	}
+
+	var buffer bytes.Buffer
+	backend.logger = zerolog.New(&buffer)
+
+	tests := map[commonlog.Level]string{
+		commonlog.Critical: "fatal",
+		commonlog.Error:    "error",
+		commonlog.Warning:  "warn",
+		commonlog.Notice:   "info",
+		commonlog.Info:     "debug",
+		commonlog.Debug:    "trace",
+	}
+
+	for level, expected := range tests {
+		buffer.Reset()
+		backend.NewMessage(level, 0, "a").Set(commonlog.MESSAGE, "hello").Send()
+
+		var object map[string]any
+		if err := json.Unmarshal(buffer.Bytes(), &object); err != nil {
+			t.Fatalf("%s: %q", err, buffer.Bytes())
+		}
+		if object[zerolog.LevelFieldName] != expected {
+			t.Errorf("%s: level = %v, expected %s", level, object[zerolog.LevelFieldName], expected)
+		}
+		if object[zerolog.MessageFieldName] != "hello" {
+			t.Errorf("%s: message = %v", level, object[zerolog.MessageFieldName])
+		}
+	}
+
+	backend.Configure(-4, nil)
+	if level := zerolog.GlobalLevel(); level != globalLevel {
+		t.Errorf("global level = %s, expected %s", level, globalLevel)
+	}
+	if message := backend.NewMessage(commonlog.Critical, 0); message != nil {
+		t.Error("expected all messages to be filtered out")
+	}
+}
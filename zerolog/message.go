@@ -2,9 +2,12 @@ package zerolog
 
 import (
 	"fmt"
+	"strconv"
+	"time"
 
 	"github.com/rs/zerolog"
 	"github.com/tliron/commonlog"
+	"github.com/tliron/go-kutil/util"
 )
 
 //
@@ -13,14 +16,49 @@ import (
 
 type Message struct {
 	event *zerolog.Event
+	time  time.Time
+
+	message string
+	file    string
+	line    int
 }
 
-func NewMessage(event *zerolog.Event) commonlog.Message {
-	return &Message{event: event}
+func NewMessage(event *zerolog.Event, time time.Time) commonlog.Message {
+	return &Message{
+		event: event,
+		time:  time,
+	}
 }
 
 // ([commonlog.Message] interface)
 func (self *Message) Set(key string, value any) commonlog.Message {
+	switch key {
+	case commonlog.MESSAGE:
+		self.message = util.ToString(value)
+		return self
+
+	case commonlog.TIME:
+		if time_, ok := commonlog.ToTime(value); ok {
+			self.time = time_
+		}
+		return self
+
+	case commonlog.FILE:
+		self.file = util.ToString(value)
+		return self
+
+	case commonlog.LINE:
+		if line, ok := value.(int); ok {
+			self.line = line
+		} else if line, err := strconv.Atoi(util.ToString(value)); err == nil {
+			self.line = line
+		}
+		return self
+
+	case commonlog.SCOPE:
+		key = ScopeFieldName
+	}
+
 	switch value_ := value.(type) {
 	case string:
 		self.event.Str(key, value_)
@@ -63,5 +101,9 @@ func (self *Message) Set(key string, value any) commonlog.Message {
 
 // ([commonlog.Message] interface)
 func (self *Message) Send() {
-	self.event.Send()
+	self.event.Time(zerolog.TimestampFieldName, self.time)
+	if self.file != "" {
+		self.event.Str(zerolog.CallerFieldName, zerolog.CallerMarshalFunc(0, self.file, self.line))
+	}
+	self.event.Msg(self.message)
 }